@@ -0,0 +1,27 @@
+package retry
+
+import "context"
+
+// Result содержит итог выполнения операции с повторными попытками
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+// Async запускает WithRetry в отдельной горутине и возвращает канал, в который будет
+// отправлен итоговый Result, после чего канал закрывается. Канал буферизован, поэтому
+// горутина завершается, даже если результат никто не читает; отмена ctx прерывает попытки.
+func Async[T any](
+	ctx context.Context,
+	config RetryConfig,
+	operationName string,
+	operationFn func(context.Context) (T, error),
+) <-chan Result[T] {
+	results := make(chan Result[T], 1)
+	go func() {
+		defer close(results)
+		value, err := WithRetry(ctx, config, operationName, operationFn)
+		results <- Result[T]{Value: value, Err: err}
+	}()
+	return results
+}
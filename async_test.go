@@ -0,0 +1,77 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// await читает единственный Result из канала Async и проверяет, что после него канал закрыт
+func await[T any](t *testing.T, results <-chan Result[T]) Result[T] {
+	t.Helper()
+	var result Result[T]
+	select {
+	case r, ok := <-results:
+		if !ok {
+			t.Fatalf("channel closed without a result")
+		}
+		result = r
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no result from Async")
+	}
+	select {
+	case r, ok := <-results:
+		if ok {
+			t.Fatalf("second result %+v, want the channel closed", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("channel not closed after the result")
+	}
+	return result
+}
+
+func TestAsyncSuccess(t *testing.T) {
+	results := Async(context.Background(), RetryConfig{Clock: newFakeClock()}, "op", failTimes(2, errRetriable))
+	if r := await(t, results); r.Err != nil || r.Value != 3 {
+		t.Fatalf("Result = %+v, want value 3 after two retries", r)
+	}
+}
+
+func TestAsyncFailure(t *testing.T) {
+	op, calls := failing(errRetriable)
+	r := await(t, Async(context.Background(), RetryConfig{Clock: newFakeClock(), MaxAttempts: 3}, "op", op))
+	requireReason(t, r.Err, AttemptsExhausted)
+	if *calls != 3 {
+		t.Fatalf("calls = %d, want 3", *calls)
+	}
+}
+
+func TestAsyncCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	results := Async(ctx, RetryConfig{Clock: newFakeClock()}, "op", func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+
+	<-started
+	cancel()
+	if r := await(t, results); !errors.Is(r.Err, context.Canceled) {
+		t.Fatalf("Result.Err = %v, want context.Canceled", r.Err)
+	}
+}
+
+func TestAsyncUnread(t *testing.T) {
+	// Горутина завершается и закрывает канал, даже если результат прочитан позже
+	done := make(chan struct{})
+	results := Async(context.Background(), RetryConfig{}, "op", func(context.Context) (int, error) {
+		defer close(done)
+		return 1, nil
+	})
+	<-done
+	if r := await(t, results); r.Err != nil || r.Value != 1 {
+		t.Fatalf("Result = %+v, want value 1", r)
+	}
+}
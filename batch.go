@@ -0,0 +1,166 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// MapConfig содержит параметры пакетной обработки
+type MapConfig struct {
+	RetryConfig     // Параметры повторных попыток для каждого элемента
+	Concurrency int // Максимальное количество одновременно обрабатываемых элементов (по умолчанию 1)
+
+	// CancelOnError прерывает весь пакет, если возвращает true для итоговой ошибки элемента:
+	// общий контекст отменяется, новые элементы не выдаются (nil = обработка продолжается)
+	CancelOnError func(error) bool
+}
+
+// MapError описывает неудачные и необработанные элементы пакетной обработки
+type MapError struct {
+	Failed       map[int]error // Ошибки по индексам элементов, для которых все попытки неудачны
+	NotAttempted []int         // Индексы элементов, не выданных воркерам из-за отмены контекста
+	Cause        error         // Ошибка контекста, если обработка была прервана
+	AbortedBy    int           // Индекс элемента, прервавшего пакет через CancelOnError (-1 = не прерван)
+}
+
+func (e *MapError) Error() string {
+	if e.AbortedBy >= 0 && e.Cause == nil {
+		return fmt.Sprintf("map aborted by item %d: %v (%d items failed, %d not attempted)",
+			e.AbortedBy, e.Failed[e.AbortedBy], len(e.Failed), len(e.NotAttempted))
+	}
+	if e.Cause != nil {
+		return fmt.Sprintf("map interrupted: %v (%d items failed, %d not attempted)",
+			e.Cause, len(e.Failed), len(e.NotAttempted))
+	}
+	first := slices.Min(e.indexes())
+	return fmt.Sprintf("%d items failed, first (item %d): %v", len(e.Failed), first, e.Failed[first])
+}
+
+func (e *MapError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed)+1)
+	if e.Cause != nil {
+		errs = append(errs, e.Cause)
+	}
+	for _, i := range e.indexes() {
+		errs = append(errs, e.Failed[i])
+	}
+	return errs
+}
+
+// indexes возвращает отсортированные индексы неудачных элементов
+func (e *MapError) indexes() []int {
+	indexes := make([]int, 0, len(e.Failed))
+	for i := range e.Failed {
+		indexes = append(indexes, i)
+	}
+	slices.Sort(indexes)
+	return indexes
+}
+
+// Map выполняет операцию с повторными попытками для каждого элемента items
+// пулом из Concurrency воркеров. Новые элементы выдаются воркерам только по мере
+// освобождения, результаты возвращаются в порядке items.
+// При отмене контекста возвращаются уже полученные результаты и MapError с Cause и
+// индексами необработанных элементов (их значения в результате нулевые). Начатые
+// элементы завершаются через отмену их контекста.
+// Все элементы выполняются под именем operationName, чтобы метки Metrics не росли с размером
+// пакета; индекс элемента добавляется в логи полем item.
+func Map[In, Out any](
+	ctx context.Context,
+	config MapConfig,
+	operationName string,
+	items []In,
+	operationFn func(context.Context, In) (Out, error),
+) ([]Out, error) {
+	if operationFn == nil {
+		return nil, ErrNilOperation
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	workers := min(max(config.Concurrency, 1), len(items))
+
+	// Общий контекст пакета, отменяемый через CancelOnError
+	parentCtx := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type itemResult struct {
+		index int
+		value Out
+		err   error
+	}
+
+	jobs := make(chan int)
+	dispatched := make([]bool, len(items))
+	results := make(chan itemResult, workers)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				value, err := WithRetry(ctx, withLogAttrs(config.RetryConfig, slog.Int("item", i)), operationName,
+					func(ctx context.Context) (Out, error) {
+						return operationFn(ctx, items[i])
+					})
+				results <- itemResult{index: i, value: value, err: err}
+			}
+		}()
+	}
+
+	// Раздаём элементы, пока контекст не отменён
+	go func() {
+		defer close(jobs)
+		for i := range items {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- i:
+				dispatched[i] = true
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	out := make([]Out, len(items))
+	failed := make(map[int]error)
+	abortedBy := -1
+	for r := range results {
+		out[r.index] = r.value
+		if r.err == nil {
+			continue
+		}
+		failed[r.index] = r.err
+		if abortedBy < 0 && config.CancelOnError != nil && config.CancelOnError(r.err) {
+			abortedBy = r.index
+			cancel()
+		}
+	}
+
+	if err := parentCtx.Err(); err != nil || abortedBy >= 0 {
+		mapErr := &MapError{Failed: failed, Cause: err, AbortedBy: abortedBy}
+		for i, ok := range dispatched {
+			if !ok {
+				mapErr.NotAttempted = append(mapErr.NotAttempted, i)
+			}
+		}
+		return out, mapErr
+	}
+	if len(failed) > 0 {
+		return out, &MapError{Failed: failed, AbortedBy: -1}
+	}
+	return out, nil
+}
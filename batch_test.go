@@ -0,0 +1,209 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// operationNames — Metrics, запоминающий имена операций
+type operationNames struct {
+	mu    sync.Mutex
+	names map[string]int
+}
+
+func (m *operationNames) Attempt(operation string, _ time.Duration, _ error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.names == nil {
+		m.names = make(map[string]int)
+	}
+	m.names[operation]++
+}
+
+func (m *operationNames) Retry(string, int)          {}
+func (m *operationNames) Success(string, int)        {}
+func (m *operationNames) GiveUp(string, int, Reason) {}
+
+func TestMapBoundedOperationName(t *testing.T) {
+	metrics := &operationNames{}
+	var logs bytes.Buffer
+	_, err := Map(context.Background(), MapConfig{
+		RetryConfig: RetryConfig{
+			Clock:       newFakeClock(),
+			MaxAttempts: 2,
+			Metrics:     metrics,
+			Logger:      slog.New(slog.NewTextHandler(&logs, nil)),
+			LogFields:   func() []slog.Attr { return []slog.Attr{slog.String("job", "sync")} },
+		},
+		Concurrency: 2,
+	}, "sync", []int{1, 2, 3}, func(_ context.Context, item int) (int, error) {
+		if item == 2 {
+			return 0, errRetriable
+		}
+		return item, nil
+	})
+	if err == nil {
+		t.Fatalf("Map() error = nil, want item 1 failure")
+	}
+
+	// Все элементы пишут метрики под одним именем, индекс элемента остаётся в логах
+	if len(metrics.names) != 1 || metrics.names["sync"] != 4 {
+		t.Fatalf("operation names = %v, want only %q with 4 attempts", metrics.names, "sync")
+	}
+	if out := logs.String(); !strings.Contains(out, "item=1") || !strings.Contains(out, "job=sync") {
+		t.Fatalf("logs do not carry item index and LogFields:\n%s", out)
+	}
+}
+
+func TestMapConcurrencyAndOrder(t *testing.T) {
+	const concurrency = 3
+	items := make([]int, 20)
+	for i := range items {
+		items[i] = len(items) - i
+	}
+
+	var inFlight, peak atomic.Int32
+	full := make(chan struct{})
+	var once sync.Once
+	out, err := Map(context.Background(), MapConfig{Concurrency: concurrency}, "op", items,
+		func(_ context.Context, item int) (int, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			// Первые элементы ждут, пока воркеры не займутся все: лимит достигается наверняка
+			if n == concurrency {
+				once.Do(func() { close(full) })
+			}
+			if item > len(items)-concurrency {
+				<-full
+			}
+			return item * 10, nil
+		})
+	if err != nil {
+		t.Fatalf("Map() error = %v", err)
+	}
+
+	if got := peak.Load(); got != concurrency {
+		t.Fatalf("peak in-flight items = %d, want %d", got, concurrency)
+	}
+	for i, item := range items {
+		if out[i] != item*10 {
+			t.Fatalf("out[%d] = %d, want %d: results not in input order", i, out[i], item*10)
+		}
+	}
+}
+
+func TestMapCanceledMidBatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	items := []int{0, 1, 2, 3, 4}
+	out, err := Map(ctx, MapConfig{RetryConfig: RetryConfig{Clock: newFakeClock()}}, "op", items,
+		func(ctx context.Context, item int) (int, error) {
+			if item == 2 {
+				cancel()
+				return 0, ctx.Err()
+			}
+			return item + 10, nil
+		})
+
+	var mapErr *MapError
+	if !errors.As(err, &mapErr) || mapErr.Cause != context.Canceled || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Map() error = %v, want MapError with Cause context.Canceled", err)
+	}
+	// Результаты завершённых элементов сохраняются
+	if out[0] != 10 || out[1] != 11 {
+		t.Fatalf("out = %v, want the results of items 0 and 1", out)
+	}
+	if _, ok := mapErr.Failed[2]; !ok || len(mapErr.Failed) != 1 {
+		t.Fatalf("Failed = %v, want only the canceled item 2", mapErr.Failed)
+	}
+	// Элемент 3 мог быть выдан воркеру до того, как раздача заметила отмену, элемент 4 — нет
+	if !slices.Contains(mapErr.NotAttempted, 4) {
+		t.Fatalf("NotAttempted = %v, want item 4 among them", mapErr.NotAttempted)
+	}
+	for _, i := range mapErr.NotAttempted {
+		if i < 3 || out[i] != 0 {
+			t.Fatalf("NotAttempted = %v with out = %v, want only items after 2 with zero results", mapErr.NotAttempted, out)
+		}
+	}
+}
+
+func TestMapCancelOnError(t *testing.T) {
+	errFatal := errors.New("fatal")
+	items := []int{0, 1, 2, 3, 4}
+	run := func(cancelOnError func(error) bool) ([]int, *MapError) {
+		aborts := cancelOnError(errFatal)
+		out, err := Map(context.Background(), MapConfig{
+			RetryConfig:   RetryConfig{Clock: newFakeClock()},
+			CancelOnError: cancelOnError,
+		}, "op", items, func(ctx context.Context, item int) (int, error) {
+			switch {
+			case item == 1:
+				return 0, errFatal
+			case item == 3:
+				return 0, errTest
+			case aborts && item > 1:
+				// Элементы после прервавшего пакет завершаются только отменой общего контекста
+				<-ctx.Done()
+				return 0, ctx.Err()
+			}
+			return item + 10, nil
+		})
+		var mapErr *MapError
+		if !errors.As(err, &mapErr) {
+			t.Fatalf("Map() error = %v, want *MapError", err)
+		}
+		return out, mapErr
+	}
+
+	t.Run("abort", func(t *testing.T) {
+		out, mapErr := run(func(err error) bool { return errors.Is(err, errFatal) })
+		if mapErr.AbortedBy != 1 || mapErr.Cause != nil {
+			t.Fatalf("AbortedBy = %d, Cause = %v, want item 1 without a context cause", mapErr.AbortedBy, mapErr.Cause)
+		}
+		if !errors.Is(mapErr.Failed[1], errFatal) || !errors.Is(mapErr, errFatal) {
+			t.Fatalf("Failed = %v, want the fatal error of item 1", mapErr.Failed)
+		}
+		if want := "map aborted by item 1"; !strings.HasPrefix(mapErr.Error(), want) {
+			t.Fatalf("Error() = %q, want prefix %q", mapErr.Error(), want)
+		}
+		if out[0] != 10 {
+			t.Fatalf("out = %v, want the result of item 0", out)
+		}
+		// Элемент 2 мог быть выдан до прерывания и отменён, элемент 4 — не выдан
+		for i, err := range mapErr.Failed {
+			if i != 1 && !errors.Is(err, context.Canceled) {
+				t.Fatalf("Failed[%d] = %v, want only canceled items besides item 1", i, err)
+			}
+		}
+		if !slices.Contains(mapErr.NotAttempted, 4) {
+			t.Fatalf("NotAttempted = %v, want item 4 among them", mapErr.NotAttempted)
+		}
+	})
+
+	t.Run("continue", func(t *testing.T) {
+		out, mapErr := run(func(error) bool { return false })
+		if mapErr.AbortedBy != -1 || mapErr.Cause != nil || len(mapErr.NotAttempted) != 0 {
+			t.Fatalf("MapError = %+v, want a batch that was not aborted", mapErr)
+		}
+		if len(mapErr.Failed) != 2 || !errors.Is(mapErr.Failed[1], errFatal) || !errors.Is(mapErr.Failed[3], errTest) {
+			t.Fatalf("Failed = %v, want items 1 and 3", mapErr.Failed)
+		}
+		if want := []int{10, 0, 12, 0, 14}; !slices.Equal(out, want) {
+			t.Fatalf("out = %v, want %v", out, want)
+		}
+	})
+}
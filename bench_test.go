@@ -0,0 +1,68 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+)
+
+func succeed(context.Context) (int, error) { return 1, nil }
+
+func BenchmarkWithRetrySuccess(b *testing.B) {
+	ctx := context.Background()
+	config := RetryConfig{}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := WithRetry(ctx, config, "op", succeed); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestWithRetrySuccessAllocs(t *testing.T) {
+	ctx := context.Background()
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = WithRetry(ctx, RetryConfig{}, "op", succeed)
+	})
+	if allocs != 0 {
+		t.Fatalf("WithRetry() success path allocates %v times, want 0", allocs)
+	}
+}
+
+// reflectClassifier — дорогой классификатор по типу: перебирает цели errors.As
+func reflectClassifier(err error) bool {
+	var (
+		netErr   *net.OpError
+		dnsErr   *net.DNSError
+		addrErr  *net.AddrError
+		httpErr  *HTTPError
+		timeout  interface{ Timeout() bool }
+		retrying *RetryError
+	)
+	switch {
+	case errors.As(err, &retrying), errors.As(err, &addrErr):
+		return false
+	case errors.As(err, &httpErr), errors.As(err, &dnsErr), errors.As(err, &timeout):
+		return true
+	}
+	return errors.As(err, &netErr)
+}
+
+func benchmarkShouldRetry(b *testing.B, shouldRetry func(error) bool) {
+	err := fmt.Errorf("query: %w", &net.OpError{Op: "read", Err: io.ErrUnexpectedEOF})
+	b.ReportAllocs()
+	for b.Loop() {
+		if !shouldRetry(err) {
+			b.Fatal("shouldRetry() = false, want true")
+		}
+	}
+}
+
+func BenchmarkShouldRetryUncached(b *testing.B) { benchmarkShouldRetry(b, reflectClassifier) }
+
+func BenchmarkShouldRetryCacheByErrorType(b *testing.B) {
+	benchmarkShouldRetry(b, CacheByErrorType(reflectClassifier))
+}
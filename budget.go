@@ -0,0 +1,51 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+// Budget — общий бюджет повторов для нескольких операций (token bucket).
+// Каждый повтор расходует один токен; токены пополняются со временем.
+// Когда бюджет исчерпан, все WithRetry с этим бюджетом прекращают повторы
+// с Reason = BudgetExhausted, не дожидаясь восстановления.
+type Budget struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	rate     float64 // Токенов в секунду
+	last     time.Time
+}
+
+// NewBudget создаёт бюджет на capacity повторов, пополняемый на refillPerSecond токенов в секунду
+func NewBudget(capacity int, refillPerSecond float64) *Budget {
+	return &Budget{
+		tokens:   float64(capacity),
+		capacity: float64(capacity),
+		rate:     refillPerSecond,
+	}
+}
+
+// Allow расходует токен на повтор; false, если бюджет исчерпан
+func (b *Budget) Allow() bool {
+	return b.allow(time.Now())
+}
+
+// allow расходует токен на момент now. WithRetry передаёт время Clock, поэтому пополнение
+// детерминировано под фейковыми часами. Отсчёт пополнения начинается с первого вызова.
+func (b *Budget) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.last.IsZero() {
+		b.last = now
+	}
+	b.tokens = min(b.capacity, b.tokens+max(now.Sub(b.last), 0).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
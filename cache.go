@@ -0,0 +1,59 @@
+package retry
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// maxCachedChain — максимальная глубина цепочки Unwrap, для которой кешируется решение
+const maxCachedChain = 4
+
+// typeChain — типы ошибок цепочки Unwrap, ключ кеша CacheByErrorType
+type typeChain [maxCachedChain]reflect.Type
+
+// CacheByErrorType оборачивает ShouldRetry, запоминая решение для цепочки конкретных
+// типов ошибки (тип самой ошибки и обёрнутых через Unwrap). Подходит только для
+// классификаторов, решающих по типу (errors.As, type switch): классификатор по значению
+// (статус HTTP, текст) получит решение для первого увиденного значения. Кеш не сбрасывается.
+// Объединённые (errors.Join) и слишком глубокие цепочки классифицируются без кеша.
+func CacheByErrorType(shouldRetry func(error) bool) func(error) bool {
+	var mu sync.RWMutex
+	cache := make(map[typeChain]bool)
+
+	return func(err error) bool {
+		key, ok := errorTypeChain(err)
+		if !ok {
+			return shouldRetry(err)
+		}
+
+		mu.RLock()
+		decision, found := cache[key]
+		mu.RUnlock()
+		if found {
+			return decision
+		}
+
+		decision = shouldRetry(err)
+		mu.Lock()
+		cache[key] = decision
+		mu.Unlock()
+		return decision
+	}
+}
+
+// errorTypeChain собирает типы цепочки Unwrap; false, если цепочку нельзя кешировать
+func errorTypeChain(err error) (typeChain, bool) {
+	var chain typeChain
+	for i := 0; err != nil; i++ {
+		if i == maxCachedChain {
+			return chain, false
+		}
+		if _, joined := err.(interface{ Unwrap() []error }); joined {
+			return chain, false
+		}
+		chain[i] = reflect.TypeOf(err)
+		err = errors.Unwrap(err)
+	}
+	return chain, true
+}
@@ -0,0 +1,89 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+)
+
+// typeClassifier — классификатор по типу: повторяет сетевые ошибки, считает вызовы
+func typeClassifier() (func(error) bool, *int) {
+	var mu sync.Mutex
+	calls := 0
+	return func(err error) bool {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		var netErr *net.OpError
+		return errors.As(err, &netErr)
+	}, &calls
+}
+
+func TestCacheByErrorType(t *testing.T) {
+	classify, calls := typeClassifier()
+	cached := CacheByErrorType(classify)
+
+	netErr := &net.OpError{Op: "dial", Err: io.ErrUnexpectedEOF}
+	otherNetErr := &net.OpError{Op: "read", Err: io.ErrUnexpectedEOF}
+	tests := []struct {
+		name      string
+		err       error
+		want      bool
+		wantCalls int // Вызовы классификатора после проверки
+	}{
+		{"first of a type", netErr, true, 1},
+		{"same type, other value", otherNetErr, true, 1},
+		{"other type", errTest, false, 2},
+		{"other type cached", errors.New("another"), false, 2},
+		{"wrapped is another chain", fmt.Errorf("call: %w", netErr), true, 3},
+		{"wrapped chain cached", fmt.Errorf("other call: %w", otherNetErr), true, 3},
+		{"joined is not cached", errors.Join(errTest, netErr), true, 4},
+		{"joined again", errors.Join(errTest, netErr), true, 5},
+	}
+	for _, tt := range tests {
+		if got := cached(tt.err); got != tt.want {
+			t.Fatalf("%s: cached(%v) = %t, want %t", tt.name, tt.err, got, tt.want)
+		}
+		if *calls != tt.wantCalls {
+			t.Fatalf("%s: classifier calls = %d, want %d", tt.name, *calls, tt.wantCalls)
+		}
+	}
+
+	// Цепочка глубже maxCachedChain классифицируется без кеша
+	deep := error(netErr)
+	for range maxCachedChain {
+		deep = fmt.Errorf("layer: %w", deep)
+	}
+	for range 2 {
+		before := *calls
+		if !cached(deep) || *calls != before+1 {
+			t.Fatalf("deep chain: want a classifier call per check, got %d calls", *calls-before)
+		}
+	}
+}
+
+func TestCacheByErrorTypeConcurrent(t *testing.T) {
+	// Запускать с -race
+	classify, _ := typeClassifier()
+	cached := CacheByErrorType(classify)
+	errs := []error{&net.OpError{Op: "dial"}, errTest, fmt.Errorf("wrap: %w", &net.OpError{Op: "dial"})}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 300 {
+				err := errs[i%len(errs)]
+				if got, want := cached(err), classify(err); got != want {
+					t.Errorf("cached(%v) = %t, want %t", err, got, want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,26 @@
+package retry
+
+import "time"
+
+// Clock задаёт источник времени для ожиданий и бюджета времени.
+//
+// After вызывается непосредственно перед тем, как цикл блокируется в ожидании задержки,
+// поэтому фейковые часы в тестах могут по этому вызову узнать, что цикл ждёт таймер,
+// и только после этого сдвигать время. Это исключает гонку между сдвигом часов
+// и моментом, когда код доходит до ожидания.
+//
+// Clock — единственный источник текущего времени в WithRetry: по нему считаются ожидания,
+// MaxElapsedTime, Deadline, TimeBasedBackoff, Pacer, пополнение Budget и HTTP-дата в Retry-After.
+// Дедлайн контекста (в том числе OperationTimeout) задан по системному времени, поэтому при старте
+// переносится на шкалу Clock остатком до него; отменяется сам контекст по-прежнему по системному времени.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock использует системное время
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
@@ -0,0 +1,245 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testEpoch — начальное время фейковых часов в тестах
+var testEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// manualClock — фейковые часы, время которых сдвигает тест через Advance.
+// Каждый вызов After сообщает задержку в waiting: цикл повторов дошёл до ожидания.
+type manualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []manualTimer
+	waiting chan time.Duration
+}
+
+type manualTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newManualClock() *manualClock {
+	return &manualClock{now: testEpoch, waiting: make(chan time.Duration, 64)}
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, manualTimer{at: c.now.Add(d), ch: ch})
+	c.mu.Unlock()
+
+	c.waiting <- d
+	return ch
+}
+
+// Advance сдвигает время на d и срабатывает наступившие таймеры
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.ch <- c.now
+	}
+	c.timers = pending
+}
+
+// fakeClock — фейковые часы, в которых ожидание проходит мгновенно: After сразу сдвигает
+// время на d и срабатывает. Задержки сохраняются в sleeps.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: testEpoch}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.sleeps = append(c.sleeps, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// Advance сдвигает время на d, например на длительность попытки
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Sleeps возвращает задержки всех ожиданий
+func (c *fakeClock) Sleeps() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.sleeps...)
+}
+
+func TestFakeClockDrivesWholeRun(t *testing.T) {
+	const attemptDuration = 500 * time.Millisecond
+	run := func() ([]time.Duration, *RetryError) {
+		clock := newFakeClock()
+		attempt := 0
+		_, err := WithRetry(context.Background(), RetryConfig{
+			Clock:          clock,
+			MaxAttempts:    100,
+			MinDelay:       time.Second,
+			MaxDelay:       time.Minute,
+			Backoff:        TimeBasedBackoff{DoublingTime: 10 * time.Second},
+			Jitter:         DeterministicJitter(42),
+			MaxElapsedTime: 10 * time.Minute,
+			Deadline:       testEpoch.Add(2 * time.Minute),
+			Budget:         NewBudget(5, 1), // Без пополнения по Clock исчерпался бы после 5 повторов
+		}, "op", func(context.Context) (int, error) {
+			attempt++
+			clock.Advance(attemptDuration)
+			if attempt == 3 {
+				retryAt := clock.Now().Add(7 * time.Second).Format(http.TimeFormat)
+				return 0, &HTTPError{StatusCode: 503, Header: http.Header{"Retry-After": {retryAt}}}
+			}
+			return 0, errRetriable
+		})
+
+		var retryErr *RetryError
+		if !errors.As(err, &retryErr) {
+			t.Fatalf("WithRetry() error = %v, want *RetryError", err)
+		}
+		return clock.Sleeps(), retryErr
+	}
+
+	sleeps, retryErr := run()
+	if retryErr.Reason != TimeBudgetExceeded {
+		t.Fatalf("Reason = %s, want %s", retryErr.Reason, TimeBudgetExceeded)
+	}
+
+	// Всё время прогона — время фейковых часов: попытки и ожидания
+	total := time.Duration(retryErr.Attempts) * attemptDuration
+	for _, d := range sleeps {
+		total += d
+	}
+	if retryErr.Elapsed != total || total > 2*time.Minute {
+		t.Fatalf("Elapsed = %s, sum of attempts and sleeps = %s, want equal and within Deadline", retryErr.Elapsed, total)
+	}
+
+	// HTTP-дата Retry-After отсчитывается от Clock (с точностью до секунды формата)
+	if hint := sleeps[2]; hint <= 6*time.Second || hint > 7*time.Second {
+		t.Fatalf("sleep after Retry-After = %s, want (6s, 7s]", hint)
+	}
+
+	// TimeBasedBackoff растит задержку по времени Clock
+	if first, last := sleeps[0], sleeps[len(sleeps)-2]; last <= first {
+		t.Fatalf("delays did not grow with elapsed time: first %s, last %s", first, last)
+	}
+
+	again, againErr := run()
+	if !slices.Equal(sleeps, again) || againErr.Elapsed != retryErr.Elapsed {
+		t.Fatalf("runs differ:\n%v\n%v", sleeps, again)
+	}
+}
+
+func TestFakeClockContextDeadline(t *testing.T) {
+	// Дедлайн контекста по системному времени переносится на шкалу фейковых часов
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	clock := newFakeClock()
+	_, err := WithRetry(ctx, RetryConfig{
+		Clock:       clock,
+		MaxAttempts: 100,
+		MinDelay:    time.Second,
+		MaxDelay:    time.Second,
+		Jitter:      noJitter,
+	}, "op", func(context.Context) (int, error) {
+		clock.Advance(500 * time.Millisecond)
+		return 0, errRetriable
+	})
+
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || retryErr.Reason != TimeBudgetExceeded {
+		t.Fatalf("WithRetry() error = %v, want RetryError with %s", err, TimeBudgetExceeded)
+	}
+	if retryErr.Elapsed > 30*time.Second || retryErr.Elapsed < 28*time.Second {
+		t.Fatalf("Elapsed = %s, want about the 30s context budget on the fake clock", retryErr.Elapsed)
+	}
+}
+
+func TestFakeClockJitterBudgetElapsed(t *testing.T) {
+	// Сид джиттера и фейковые часы делают прогон детерминированным: итоговое Elapsed
+	// совпадает с расписанием, посчитанным по NextDelay с тем же джиттером и урезанным до бюджета
+	const attemptDuration = 300 * time.Millisecond
+	config := RetryConfig{
+		MaxAttempts:    20,
+		MinDelay:       500 * time.Millisecond,
+		MaxDelay:       4 * time.Second,
+		Jitter:         DeterministicJitter(7),
+		MaxElapsedTime: 11 * time.Second,
+	}
+
+	var want []time.Duration
+	elapsed, truncated := time.Duration(0), false
+	for attempt := 1; ; attempt++ {
+		elapsed += attemptDuration
+		remaining := config.MaxElapsedTime - elapsed
+		if attempt == config.MaxAttempts || remaining < config.MinDelay {
+			break
+		}
+		delay := min(max(config.Jitter(attempt, config.NextDelay(attempt+1)), config.MinDelay), config.MaxDelay)
+		if remaining-config.MinDelay < delay {
+			delay, truncated = remaining-config.MinDelay, true
+		}
+		want = append(want, delay)
+		elapsed += delay
+	}
+
+	clock := newFakeClock()
+	config.Clock = clock
+	op := func(context.Context) (int, error) {
+		clock.Advance(attemptDuration)
+		return 0, errRetriable
+	}
+	_, err := WithRetry(context.Background(), config, "op", op)
+
+	retryErr := requireReason(t, err, TimeBudgetExceeded)
+	if sleeps := clock.Sleeps(); !slices.Equal(sleeps, want) {
+		t.Fatalf("sleeps = %v, want %v", sleeps, want)
+	}
+	if retryErr.Elapsed != elapsed {
+		t.Fatalf("Elapsed = %s, want exactly %s", retryErr.Elapsed, elapsed)
+	}
+	if retryErr.Attempts != len(want)+1 {
+		t.Fatalf("Attempts = %d, want %d", retryErr.Attempts, len(want)+1)
+	}
+	if !truncated {
+		t.Fatalf("schedule %v never hit the budget, the test does not cover truncation", want)
+	}
+}
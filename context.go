@@ -0,0 +1,33 @@
+package retry
+
+import "context"
+
+// disabledKey — ключ контекста для отключения повторных попыток
+type disabledKey struct{}
+
+// WithDisabled возвращает контекст, в котором WithRetry выполняет операцию
+// ровно один раз без повторов (например, для критичных по задержке путей)
+func WithDisabled(ctx context.Context) context.Context {
+	return context.WithValue(ctx, disabledKey{}, true)
+}
+
+// Disabled сообщает, отключены ли повторные попытки в контексте
+func Disabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(disabledKey{}).(bool)
+	return disabled
+}
+
+// maxAttemptsKey — ключ контекста для переопределения MaxAttempts
+type maxAttemptsKey struct{}
+
+// WithMaxAttempts возвращает контекст, в котором WithRetry использует n вместо
+// MaxAttempts конфигурации (например, больше попыток для фоновой задачи). n <= 0 не действует.
+func WithMaxAttempts(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, maxAttemptsKey{}, n)
+}
+
+// MaxAttemptsFrom возвращает переопределение MaxAttempts из контекста, если оно задано
+func MaxAttemptsFrom(ctx context.Context) (int, bool) {
+	n, ok := ctx.Value(maxAttemptsKey{}).(int)
+	return n, ok && n > 0
+}
@@ -0,0 +1,50 @@
+package retry
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithMaxAttemptsOverride(t *testing.T) {
+	config := RetryConfig{Clock: newFakeClock(), MaxAttempts: 3}
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want int
+	}{
+		{"config", context.Background(), 3},
+		{"context raises", WithMaxAttempts(context.Background(), 5), 5},
+		{"context lowers", WithMaxAttempts(context.Background(), 1), 1},
+		{"non-positive ignored", WithMaxAttempts(context.Background(), 0), 3},
+		{"disabled wins", WithDisabled(WithMaxAttempts(context.Background(), 5)), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, calls := failing(errRetriable)
+			_, err := WithRetry(tt.ctx, config, "op", op)
+			requireReason(t, err, AttemptsExhausted)
+			if *calls != tt.want {
+				t.Fatalf("calls = %d, want %d", *calls, tt.want)
+			}
+
+			// Step учитывает то же переопределение
+			op, _ = failing(errRetriable)
+			if res := Step(tt.ctx, config, "op", tt.want, op); !res.Done {
+				t.Fatalf("Step(%d) not done, want the same limit as WithRetry", tt.want)
+			}
+			if res := Step(tt.ctx, config, "op", tt.want-1, op); tt.want > 1 && res.Done {
+				t.Fatalf("Step(%d) done, want a retry below the limit", tt.want-1)
+			}
+		})
+	}
+}
+
+func TestMaxAttemptsOverrideKeepsClassLimits(t *testing.T) {
+	config := RetryConfig{Clock: newFakeClock(), MaxAttempts: 3, MaxAttemptsFor: func(error) int { return 2 }}
+	op, calls := failing(errRetriable)
+	_, err := WithRetry(WithMaxAttempts(context.Background(), 10), config, "op", op)
+	requireReason(t, err, AttemptsExhausted)
+	if *calls != 2 {
+		t.Fatalf("calls = %d, want the MaxAttemptsFor limit 2", *calls)
+	}
+}
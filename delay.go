@@ -0,0 +1,232 @@
+package retry
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/alfzs/backoff"
+)
+
+// NextDelay возвращает задержку, которую конфигурация использует перед попыткой attempt
+// (после неудачи попытки attempt-1). Jitter не применяется, поэтому результат — оценка
+// средней задержки; подсказки ошибок и множители Classify не учитываются.
+func (c RetryConfig) NextDelay(attempt int) time.Duration {
+	config := withDefaults(c)
+	failed := attempt - 1
+	if failed < 1 {
+		return 0
+	}
+
+	strategy := config.Backoff
+	if strategy == nil {
+		strategy = ExponentialBackoff
+	}
+
+	delay := proposedDelay(config, failed, nil, 1, func(attempt int, minDelay, maxDelay time.Duration) time.Duration {
+		return strategy.Delay(exponentAttempt(config, strategy, attempt), minDelay, maxDelay)
+	})
+	return finalDelay(config, failed, delay)
+}
+
+// nextDelay вычисляет задержку после неудачной попытки attempt, завершившейся ошибкой err,
+// спустя elapsed от начала повторов. multiplier применяется к задержке стратегии,
+// но не к подсказке DelayHinter.
+func nextDelay(config RetryConfig, attempt int, elapsed time.Duration, err error, multiplier float64) time.Duration {
+	strategy := func(attempt int, minDelay, maxDelay time.Duration) time.Duration {
+		return strategyDelay(config, attempt, elapsed, minDelay, maxDelay)
+	}
+
+	return finalDelay(config, attempt, proposedDelay(config, attempt, err, multiplier, strategy))
+}
+
+// finalDelay применяет к задержке DelayHook, DelayRounding и последним — потолок AbsoluteMaxDelay,
+// поэтому его не превышают ни множитель Classify, ни FixedDelays, ни подсказка DelayHinter
+func finalDelay(config RetryConfig, attempt int, delay time.Duration) time.Duration {
+	if config.DelayHook != nil {
+		delay = min(max(config.DelayHook(attempt, delay), 0), maxSleep(config))
+	}
+	delay = roundDelay(config, delay)
+	if config.AbsoluteMaxDelay > 0 {
+		delay = min(delay, config.AbsoluteMaxDelay)
+	}
+	return delay
+}
+
+// roundDelay округляет задержку до ближайшего кратного DelayRounding в пределах
+// [MinDelay, maxSleep]. Нулевая задержка (повтор без ожидания) не меняется.
+func roundDelay(config RetryConfig, delay time.Duration) time.Duration {
+	if config.DelayRounding <= 0 || delay <= 0 {
+		return delay
+	}
+	return min(max(delay.Round(config.DelayRounding), config.MinDelay), maxSleep(config))
+}
+
+// strategyDelay вычисляет задержку стратегии Backoff с jitter.
+// Без Backoff и Jitter используется экспоненциальный backoff пакета backoff.
+func strategyDelay(config RetryConfig, attempt int, elapsed, minDelay, maxDelay time.Duration) time.Duration {
+	strategy := config.Backoff
+	if strategy == nil {
+		strategy = ExponentialBackoff
+	}
+	attempt = exponentAttempt(config, strategy, attempt)
+
+	if config.Backoff == nil && config.Jitter == nil {
+		return ExponentialDelay(attempt, minDelay, maxDelay)
+	}
+
+	jitter := config.Jitter
+	if jitter == nil {
+		jitter = defaultJitter
+	}
+
+	base := strategy.Delay(attempt, minDelay, maxDelay)
+	if timed, ok := strategy.(ElapsedBackoffStrategy); ok {
+		base = timed.DelayAfter(elapsed, minDelay, maxDelay)
+	}
+	delay := jitter(attempt, base)
+	return min(max(delay, minDelay), maxSleep(config))
+}
+
+// maxSleep возвращает потолок итоговой задержки после jitter: AbsoluteMaxDelay или MaxDelay
+func maxSleep(config RetryConfig) time.Duration {
+	if config.AbsoluteMaxDelay > 0 {
+		return config.AbsoluteMaxDelay
+	}
+	return config.MaxDelay
+}
+
+// startupDelay вычисляет задержку первой попытки из [0, StartupJitter].
+// Коэффициент Jitter из [0.5, 1.5) переводится в долю из [0, 1).
+func startupDelay(config RetryConfig) time.Duration {
+	jitter := config.Jitter
+	if jitter == nil {
+		jitter = defaultJitter
+	}
+	const unit = time.Second
+	fraction := float64(jitter(0, unit))/float64(unit) - 0.5
+	fraction = min(max(fraction, 0), 1)
+	return time.Duration(float64(config.StartupJitter) * fraction)
+}
+
+// exponentAttempt ограничивает номер попытки для экспоненциальной стратегии так,
+// чтобы показатель 2^(attempt-1) не превышал MaxExponent
+func exponentAttempt(config RetryConfig, strategy BackoffStrategy, attempt int) int {
+	if _, ok := strategy.(exponentialBackoff); ok && config.MaxExponent > 0 {
+		return min(attempt, config.MaxExponent+1)
+	}
+	return attempt
+}
+
+// defaultJitter умножает задержку на случайный коэффициент из [0.5, 1.5), как пакет backoff
+func defaultJitter(_ int, delay time.Duration) time.Duration {
+	return time.Duration(float64(delay) * (0.5 + rand.Float64()))
+}
+
+// proposedDelay вычисляет задержку по стратегии конфигурации без учёта DelayHook.
+// strategy вычисляет задержку стратегии backoff (с jitter или без).
+func proposedDelay(
+	config RetryConfig,
+	attempt int,
+	err error,
+	multiplier float64,
+	strategy func(attempt int, minDelay, maxDelay time.Duration) time.Duration,
+) time.Duration {
+	if hint, ok := delayHint(config, err); ok {
+		return min(hint, config.MaxDelay)
+	}
+
+	if n := len(config.FixedDelays); n > 0 {
+		i := attempt - 1
+		if i >= n {
+			if config.LoopFixedDelays {
+				i %= n
+			} else {
+				i = n - 1
+			}
+		}
+		return scaleDelay(config.FixedDelays[i], multiplier)
+	}
+
+	return scaleDelay(strategy(attempt, config.MinDelay, config.MaxDelay), multiplier)
+}
+
+// delayHint возвращает подсказку DelayHinter из цепочки err. Для HTTPError время
+// отсчитывается по Clock конфигурации, а не по системным часам.
+func delayHint(config RetryConfig, err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		if hint, ok := httpErr.delayHintAt(config.Clock.Now()); ok {
+			return hint, true
+		}
+	}
+	var hinter DelayHinter
+	if errors.As(err, &hinter) {
+		return hinter.DelayHint()
+	}
+	return 0, false
+}
+
+// ExponentialDelay вычисляет экспоненциальную задержку с jitter так же, как WithRetry по умолчанию:
+// minDelay*2^(attempt-1) с jitter пакета backoff, без переполнения time.Duration.
+// Результат не превышает maxDelay и положителен при minDelay > 0; при minDelay <= 0
+// задержки нет и возвращается 0. Вариант без jitter — ExponentialBackoff.
+func ExponentialDelay(attempt int, minDelay, maxDelay time.Duration) time.Duration {
+	// После достижения maxDelay рост не нужен: ограничиваем показатель,
+	// чтобы minDelay*2^(attempt-1) не переполнился при больших attempt
+	attempt = min(attempt, saturationAttempt(minDelay, maxDelay))
+
+	delay := min(backoff.CalculateExponentialBackoff(attempt, minDelay, maxDelay), maxDelay)
+	if delay <= 0 {
+		return max(minDelay, 0)
+	}
+	return delay
+}
+
+// baseExponentialDelay вычисляет экспоненциальную задержку без jitter: minDelay*2^(attempt-1), не больше maxDelay
+func baseExponentialDelay(attempt int, minDelay, maxDelay time.Duration) time.Duration {
+	attempt = min(max(attempt, 1), saturationAttempt(minDelay, maxDelay))
+	return min(minDelay<<(attempt-1), maxDelay)
+}
+
+// saturationAttempt возвращает номер попытки, на которой экспоненциальная задержка достигает maxDelay
+func saturationAttempt(minDelay, maxDelay time.Duration) int {
+	if minDelay <= 0 {
+		return 1
+	}
+	attempt := 1
+	for delay := minDelay; delay < maxDelay && delay <= math.MaxInt64/2; delay *= 2 {
+		attempt++
+	}
+	return attempt
+}
+
+// DeterministicJitter возвращает Jitter, зависящий только от seed и номера попытки:
+// задержка умножается на коэффициент из [0.5, 1.5), как у jitter по умолчанию, но
+// одинаковые (seed, attempt) всегда дают одинаковую задержку независимо от порядка вызовов.
+func DeterministicJitter(seed uint64) func(attempt int, delay time.Duration) time.Duration {
+	return func(attempt int, delay time.Duration) time.Duration {
+		factor := 0.5 + float64(splitmix64(seed^uint64(attempt))>>11)/(1<<53)
+		return time.Duration(float64(delay) * factor)
+	}
+}
+
+// splitmix64 — быстрый хеш с хорошим перемешиванием битов
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// scaleDelay умножает задержку на множитель классификатора
+func scaleDelay(delay time.Duration, multiplier float64) time.Duration {
+	if multiplier == 1 {
+		return delay
+	}
+	return time.Duration(float64(delay) * multiplier)
+}
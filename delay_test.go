@@ -0,0 +1,321 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// hintError — ошибка с подсказкой задержки DelayHinter
+type hintError time.Duration
+
+func (e hintError) Error() string                    { return "hint " + time.Duration(e).String() }
+func (e hintError) DelayHint() (time.Duration, bool) { return time.Duration(e), true }
+
+func TestAbsoluteMaxDelayManySeeds(t *testing.T) {
+	const ceiling = 500 * time.Millisecond
+	configs := map[string]RetryConfig{
+		"exponential": {MaxAttempts: 12, MaxDelay: 10 * time.Second},
+		"linear":      {MaxAttempts: 12, MaxDelay: 10 * time.Second, Backoff: LinearBackoff},
+		"fixed":       {FixedDelays: []time.Duration{time.Second, 5 * time.Second}},
+		"hook":        {DelayHook: func(int, time.Duration) time.Duration { return time.Hour }},
+		"rounding":    {MinDelay: time.Second, DelayRounding: 300 * time.Millisecond},
+	}
+
+	for name, config := range configs {
+		config.AbsoluteMaxDelay = ceiling
+		for seed := range int64(500) {
+			for i, delay := range Simulate(config, 11, seed) {
+				if delay > ceiling {
+					t.Fatalf("%s, seed %d: delay %d = %s, want at most %s", name, seed, i+1, delay, ceiling)
+				}
+			}
+		}
+	}
+}
+
+func TestAbsoluteMaxDelayAppliedLast(t *testing.T) {
+	const ceiling = 500 * time.Millisecond
+	tests := []struct {
+		name       string
+		config     RetryConfig
+		err        error
+		multiplier float64
+	}{
+		{"classify multiplier", RetryConfig{MaxDelay: 10 * time.Second}, errRetriable, 100},
+		{"fixed delay", RetryConfig{FixedDelays: []time.Duration{5 * time.Second}}, errRetriable, 1},
+		{"delay hint", RetryConfig{MaxDelay: time.Second}, hintError(900 * time.Millisecond), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := withDefaults(tt.config)
+			config.AbsoluteMaxDelay = ceiling
+			for seed := range uint64(500) {
+				config.Jitter = DeterministicJitter(seed)
+				for attempt := 1; attempt <= 10; attempt++ {
+					if delay := nextDelay(config, attempt, 0, tt.err, tt.multiplier); delay > ceiling {
+						t.Fatalf("seed %d, attempt %d: delay = %s, want at most %s", seed, attempt, delay, ceiling)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAbsoluteMaxDelayWithRetry(t *testing.T) {
+	const ceiling = 300 * time.Millisecond
+	clock := newFakeClock()
+	op, _ := failing(errRetriable)
+	_, _ = WithRetry(context.Background(), RetryConfig{
+		Clock:            clock,
+		MaxAttempts:      10,
+		MaxDelay:         10 * time.Second,
+		AbsoluteMaxDelay: ceiling,
+		Classify:         func(error) (bool, float64) { return true, 4 },
+	}, "op", op)
+
+	sleeps := clock.Sleeps()
+	if len(sleeps) != 9 {
+		t.Fatalf("sleeps = %d, want 9", len(sleeps))
+	}
+	for i, delay := range sleeps {
+		if delay > ceiling {
+			t.Fatalf("sleep %d = %s, want at most %s", i+1, delay, ceiling)
+		}
+	}
+}
+
+func TestStartupJitter(t *testing.T) {
+	const startup = 10 * time.Second
+	run := func(seed uint64) (first time.Duration, startedAt time.Time) {
+		clock := newFakeClock()
+		_, err := WithRetry(context.Background(), RetryConfig{
+			Clock:         clock,
+			StartupJitter: startup,
+			Jitter:        DeterministicJitter(seed),
+		}, "op", func(context.Context) (int, error) {
+			startedAt = clock.Now()
+			return 1, nil
+		})
+		if err != nil {
+			t.Fatalf("WithRetry() error = %v", err)
+		}
+		sleeps := clock.Sleeps()
+		if len(sleeps) != 1 {
+			t.Fatalf("sleeps = %v, want one startup delay", sleeps)
+		}
+		return sleeps[0], startedAt
+	}
+
+	distinct := make(map[time.Duration]bool)
+	for seed := range uint64(50) {
+		delay, startedAt := run(seed)
+		if delay < 0 || delay > startup {
+			t.Fatalf("seed %d: startup delay %s outside [0, %s]", seed, delay, startup)
+		}
+		if startedAt.Sub(testEpoch) != delay {
+			t.Fatalf("seed %d: first attempt at %s, want after the %s startup delay", seed, startedAt.Sub(testEpoch), delay)
+		}
+		if again, _ := run(seed); again != delay {
+			t.Fatalf("seed %d: startup delay %s then %s, want the same for one seed", seed, delay, again)
+		}
+		distinct[delay] = true
+	}
+	if len(distinct) < 10 {
+		t.Fatalf("%d distinct startup delays over 50 seeds, want them spread", len(distinct))
+	}
+}
+
+func TestStartupJitterCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	op, calls := failing(errRetriable)
+	_, err := WithRetry(ctx, RetryConfig{Clock: newManualClock(), StartupJitter: time.Hour}, "op", op)
+	if !errors.Is(err, context.Canceled) || *calls != 0 {
+		t.Fatalf("WithRetry() error = %v after %d calls, want context.Canceled before the first attempt", err, *calls)
+	}
+}
+
+func TestStartupJitterWithinOperationTimeout(t *testing.T) {
+	// Задержка старта входит в OperationTimeout: таймаут истекает до первой попытки
+	fullStartup := func(_ int, delay time.Duration) time.Duration { return delay * 3 / 2 }
+	op, calls := failing(errRetriable)
+	began := time.Now()
+	_, err := WithRetry(context.Background(), RetryConfig{
+		OperationTimeout: 20 * time.Millisecond,
+		StartupJitter:    200 * time.Millisecond,
+		Jitter:           fullStartup,
+	}, "op", op)
+
+	retryErr := requireReason(t, err, TimeBudgetExceeded)
+	if *calls != 0 || retryErr.Attempts != 0 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("calls = %d, Attempts = %d, error = %v, want no attempt and context.DeadlineExceeded",
+			*calls, retryErr.Attempts, err)
+	}
+	if elapsed := time.Since(began); elapsed >= 150*time.Millisecond {
+		t.Fatalf("WithRetry() returned after %s, want about the 20ms OperationTimeout", elapsed)
+	}
+}
+
+func TestDelayRounding(t *testing.T) {
+	const rounding = 50 * time.Millisecond
+	base := RetryConfig{MaxAttempts: 8, MinDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}
+
+	for seed := range uint64(50) {
+		rounded := base
+		rounded.DelayRounding = rounding
+		rounded.Jitter = DeterministicJitter(seed)
+		clock := newFakeClock()
+		rounded.Clock = clock
+		op, _ := failing(errRetriable)
+		_, err := WithRetry(context.Background(), rounded, "op", op)
+		requireReason(t, err, AttemptsExhausted)
+
+		// Без округления тот же сид даёт исходные задержки; округлённые отличаются не больше чем на полшага
+		base.Jitter = DeterministicJitter(seed)
+		exact := Simulate(base, 7, 0)
+		sleeps := clock.Sleeps()
+		if len(sleeps) != len(exact) {
+			t.Fatalf("seed %d: sleeps = %v, want %d delays", seed, sleeps, len(exact))
+		}
+		for i, delay := range sleeps {
+			if delay%rounding != 0 {
+				t.Fatalf("seed %d: delay %d = %s, not a multiple of %s", seed, i+1, delay, rounding)
+			}
+			if diff := (delay - exact[i]).Abs(); diff > rounding/2 {
+				t.Fatalf("seed %d: delay %d = %s, unrounded %s, want within %s", seed, i+1, delay, exact[i], rounding/2)
+			}
+		}
+	}
+}
+
+func TestRoundDelayBounds(t *testing.T) {
+	config := RetryConfig{MinDelay: 120 * time.Millisecond, MaxDelay: 950 * time.Millisecond, DelayRounding: 100 * time.Millisecond}
+	tests := []struct {
+		delay, want time.Duration
+	}{
+		{0, 0}, // повтор без ожидания не округляется
+		{130 * time.Millisecond, 120 * time.Millisecond}, // не меньше MinDelay
+		{260 * time.Millisecond, 300 * time.Millisecond},
+		{340 * time.Millisecond, 300 * time.Millisecond},
+		{960 * time.Millisecond, 950 * time.Millisecond}, // не больше MaxDelay
+	}
+	for _, tt := range tests {
+		if got := roundDelay(config, tt.delay); got != tt.want {
+			t.Fatalf("roundDelay(%s) = %s, want %s", tt.delay, got, tt.want)
+		}
+	}
+
+	config.DelayRounding = 0
+	if got := roundDelay(config, 137*time.Millisecond); got != 137*time.Millisecond {
+		t.Fatalf("roundDelay() without DelayRounding = %s, want the delay unchanged", got)
+	}
+}
+
+func TestFixedDelays(t *testing.T) {
+	delays := []time.Duration{time.Second, 2 * time.Second, 5 * time.Second}
+	tests := []struct {
+		name        string
+		maxAttempts int
+		loop        bool
+		want        []time.Duration
+	}{
+		{"MaxAttempts from the list", 0, false, []time.Duration{time.Second, 2 * time.Second, 5 * time.Second}},
+		{"last delay repeated", 6, false, []time.Duration{time.Second, 2 * time.Second, 5 * time.Second, 5 * time.Second, 5 * time.Second}},
+		{"list looped", 6, true, []time.Duration{time.Second, 2 * time.Second, 5 * time.Second, time.Second, 2 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			op, calls := failing(errRetriable)
+			_, err := WithRetry(context.Background(), RetryConfig{
+				Clock:           clock,
+				MaxAttempts:     tt.maxAttempts,
+				FixedDelays:     delays,
+				LoopFixedDelays: tt.loop,
+			}, "op", op)
+			requireReason(t, err, AttemptsExhausted)
+			if *calls != len(tt.want)+1 {
+				t.Fatalf("calls = %d, want %d", *calls, len(tt.want)+1)
+			}
+			if sleeps := clock.Sleeps(); !slices.Equal(sleeps, tt.want) {
+				t.Fatalf("sleeps = %v, want %v", sleeps, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelayHook(t *testing.T) {
+	const jittered = 1234 * time.Millisecond
+	tests := []struct {
+		name string
+		hook func(attempt int, proposed time.Duration) time.Duration
+		want time.Duration
+	}{
+		{"result used", func(int, time.Duration) time.Duration { return 3 * time.Second }, 3 * time.Second},
+		{"zero retries immediately", func(int, time.Duration) time.Duration { return 0 }, 0},
+		{"clamped to MaxDelay", func(int, time.Duration) time.Duration { return time.Hour }, 5 * time.Second},
+		{"negative treated as zero", func(int, time.Duration) time.Duration { return -time.Second }, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			var proposed []time.Duration
+			op, _ := failing(errRetriable)
+			_, err := WithRetry(context.Background(), RetryConfig{
+				Clock:       clock,
+				MaxAttempts: 3,
+				MaxDelay:    5 * time.Second,
+				Jitter:      func(int, time.Duration) time.Duration { return jittered },
+				DelayHook: func(attempt int, delay time.Duration) time.Duration {
+					proposed = append(proposed, delay)
+					return tt.hook(attempt, delay)
+				},
+			}, "op", op)
+			requireReason(t, err, AttemptsExhausted)
+
+			// Хук получает задержку уже после jitter
+			if !slices.Equal(proposed, []time.Duration{jittered, jittered}) {
+				t.Fatalf("proposed delays = %v, want the jittered %s twice", proposed, jittered)
+			}
+			if sleeps := clock.Sleeps(); !slices.Equal(sleeps, []time.Duration{tt.want, tt.want}) {
+				t.Fatalf("sleeps = %v, want %s twice", sleeps, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeterministicJitter(t *testing.T) {
+	const seed, attempts, base = 42, 50, time.Second
+
+	forward := make([]time.Duration, attempts+1)
+	jitter := DeterministicJitter(seed)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		forward[attempt] = jitter(attempt, base)
+		if d := forward[attempt]; d < base/2 || d >= base*3/2 {
+			t.Fatalf("jitter(%d, 1s) = %s, want within [500ms, 1.5s)", attempt, d)
+		}
+	}
+
+	// Новый экземпляр с тем же seed в обратном порядке и повторные вызовы дают те же задержки
+	again := DeterministicJitter(seed)
+	for attempt := attempts; attempt >= 1; attempt-- {
+		for range 2 {
+			if got := again(attempt, base); got != forward[attempt] {
+				t.Fatalf("jitter(%d) = %s in reverse order, want %s", attempt, got, forward[attempt])
+			}
+		}
+	}
+
+	// Другой seed даёт другое расписание
+	other := DeterministicJitter(seed + 1)
+	same := true
+	for attempt := 1; attempt <= attempts; attempt++ {
+		same = same && other(attempt, base) == forward[attempt]
+	}
+	if same {
+		t.Fatalf("seeds %d and %d produced the same delays", seed, seed+1)
+	}
+}
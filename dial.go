@@ -0,0 +1,55 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// ContextDialer устанавливает сетевые соединения (например, *net.Dialer)
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// DialContext устанавливает соединение с повторными попытками через net.Dialer по умолчанию
+func DialContext(ctx context.Context, config RetryConfig, network, address string) (net.Conn, error) {
+	return DialWith(ctx, config, &net.Dialer{}, network, address)
+}
+
+// DialWith устанавливает соединение с повторными попытками через заданный dialer.
+// Если ShouldRetry не задан, повторяются отказ в соединении, таймауты и временные ошибки DNS;
+// некорректный адрес и несуществующий хост не повторяются. Для nil dialer возвращается ErrNilOperation.
+func DialWith(
+	ctx context.Context,
+	config RetryConfig,
+	dialer ContextDialer,
+	network, address string,
+) (net.Conn, error) {
+	if dialer == nil {
+		return nil, ErrNilOperation
+	}
+	if config.ShouldRetry == nil {
+		config.ShouldRetry = shouldRetryDial
+	}
+
+	return WithRetry(ctx, config, "dial "+network+" "+address, func(ctx context.Context) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	})
+}
+
+// shouldRetryDial классифицирует ошибки установки соединения
+func shouldRetryDial(err error) bool {
+	// Некорректный адрес не исправится сам
+	var addrErr *net.AddrError
+	var parseErr *net.ParseError
+	if errors.As(err, &addrErr) || errors.As(err, &parseErr) {
+		return false
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+
+	return shouldRetryError(err)
+}
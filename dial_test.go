@@ -0,0 +1,78 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// listenAfter — dialer, который открывает listener на address перед попыткой номер n
+type listenAfter struct {
+	t        *testing.T
+	n        int
+	address  string
+	attempts int
+	listener net.Listener
+}
+
+func (d *listenAfter) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.attempts++
+	if d.attempts == d.n {
+		listener, err := net.Listen(network, d.address)
+		if err != nil {
+			d.t.Fatalf("net.Listen(%s) error = %v", d.address, err)
+		}
+		d.listener = listener
+	}
+	return (&net.Dialer{}).DialContext(ctx, network, address)
+}
+
+func TestDialWithListenerAfterAttempts(t *testing.T) {
+	// Свободный порт: слушаем и сразу закрываем, поэтому первые попытки получают отказ в соединении
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	dialer := &listenAfter{t: t, n: 3, address: address}
+	conn, err := DialWith(context.Background(), RetryConfig{
+		Clock:       newFakeClock(),
+		MaxAttempts: 5,
+		MinDelay:    time.Millisecond,
+		MaxDelay:    time.Millisecond,
+	}, dialer, "tcp", address)
+	if dialer.listener != nil {
+		defer dialer.listener.Close()
+	}
+	if err != nil {
+		t.Fatalf("DialWith() error = %v", err)
+	}
+	conn.Close()
+	if dialer.attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", dialer.attempts)
+	}
+}
+
+func TestDialContextInvalidAddress(t *testing.T) {
+	metrics := &callMetrics{}
+	_, err := DialContext(context.Background(), RetryConfig{
+		Clock:       newFakeClock(),
+		MaxAttempts: 5,
+		Metrics:     metrics,
+	}, "tcp", "127.0.0.1:notaport")
+
+	retryErr := requireReason(t, err, NonRetriable)
+	if retryErr.Attempts != 1 || len(metrics.calls) != 2 {
+		t.Fatalf("Attempts = %d, metrics = %v, want a single attempt", retryErr.Attempts, metrics.calls)
+	}
+}
+
+func TestDialWithNilDialer(t *testing.T) {
+	if _, err := DialWith(context.Background(), RetryConfig{}, nil, "tcp", "127.0.0.1:1"); !errors.Is(err, ErrNilOperation) {
+		t.Fatalf("DialWith(nil dialer) error = %v, want ErrNilOperation", err)
+	}
+}
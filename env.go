@@ -0,0 +1,94 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FromEnv читает конфигурацию из переменных окружения с префиксом prefix:
+//
+//	PREFIX_MAX_ATTEMPTS       число попыток
+//	PREFIX_MIN_DELAY          минимальная задержка (формат time.ParseDuration, например 100ms)
+//	PREFIX_MAX_DELAY          максимальная задержка
+//	PREFIX_MAX_ELAPSED_TIME   ограничение общего времени
+//	PREFIX_BACKOFF            имя стратегии (exponential, linear, constant, fibonacci)
+//	PREFIX_JITTER             false отключает jitter
+//
+// Незаданные переменные оставляют значения по умолчанию. Некорректные значения
+// возвращаются одной ошибкой с перечнем всех проблем.
+func FromEnv(prefix string) (RetryConfig, error) {
+	var config RetryConfig
+	var errs []error
+
+	if value, name, ok := lookupEnv(prefix, "MAX_ATTEMPTS"); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			errs = append(errs, fmt.Errorf("retry: %s: want positive integer, got %q", name, value))
+		}
+		config.MaxAttempts = n
+	}
+
+	durations := []struct {
+		key    string
+		target *time.Duration
+	}{
+		{"MIN_DELAY", &config.MinDelay},
+		{"MAX_DELAY", &config.MaxDelay},
+		{"MAX_ELAPSED_TIME", &config.MaxElapsedTime},
+	}
+	for _, d := range durations {
+		value, name, ok := lookupEnv(prefix, d.key)
+		if !ok {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			errs = append(errs, fmt.Errorf("retry: %s: want positive duration, got %q", name, value))
+			continue
+		}
+		*d.target = parsed
+	}
+	if effective := withDefaults(config); effective.MinDelay > effective.MaxDelay {
+		errs = append(errs, fmt.Errorf("retry: min delay %s exceeds max delay %s", effective.MinDelay, effective.MaxDelay))
+	}
+
+	if value, name, ok := lookupEnv(prefix, "BACKOFF"); ok {
+		if _, err := ParseBackoff(value); err != nil {
+			errs = append(errs, fmt.Errorf("%w (%s)", err, name))
+		}
+		config.BackoffName = value
+	}
+
+	if value, name, ok := lookupEnv(prefix, "JITTER"); ok {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("retry: %s: want boolean, got %q", name, value))
+		} else if !enabled {
+			config.Jitter = noJitter
+		}
+	}
+
+	if len(errs) > 0 {
+		return RetryConfig{}, errors.Join(errs...)
+	}
+	return config, nil
+}
+
+// lookupEnv возвращает непустое значение переменной PREFIX_KEY и её имя
+func lookupEnv(prefix, key string) (value, name string, ok bool) {
+	name = key
+	if prefix != "" {
+		name = strings.TrimSuffix(prefix, "_") + "_" + key
+	}
+	value = strings.TrimSpace(os.Getenv(name))
+	return value, name, value != ""
+}
+
+// noJitter оставляет задержку стратегии без случайного разброса
+func noJitter(_ int, delay time.Duration) time.Duration {
+	return delay
+}
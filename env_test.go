@@ -0,0 +1,88 @@
+package retry
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFromEnvParsing(t *testing.T) {
+	t.Setenv("APP_RETRY_MAX_ATTEMPTS", "7")
+	t.Setenv("APP_RETRY_MIN_DELAY", "250ms")
+	t.Setenv("APP_RETRY_MAX_DELAY", " 4s ")
+	t.Setenv("APP_RETRY_MAX_ELAPSED_TIME", "1m")
+	t.Setenv("APP_RETRY_BACKOFF", "linear")
+	t.Setenv("APP_RETRY_JITTER", "false")
+
+	// Префикс с завершающим подчёркиванием даёт те же имена
+	for _, prefix := range []string{"APP_RETRY", "APP_RETRY_"} {
+		config, err := FromEnv(prefix)
+		if err != nil {
+			t.Fatalf("FromEnv(%q) error = %v", prefix, err)
+		}
+		if config.MaxAttempts != 7 || config.MinDelay != 250*time.Millisecond || config.MaxDelay != 4*time.Second ||
+			config.MaxElapsedTime != time.Minute || config.BackoffName != "linear" {
+			t.Fatalf("FromEnv(%q) = %+v, want the values from the environment", prefix, config)
+		}
+		if config.Jitter == nil || config.Jitter(1, time.Second) != time.Second {
+			t.Fatalf("FromEnv(%q): JITTER=false must disable jitter", prefix)
+		}
+	}
+}
+
+func TestFromEnvDefaults(t *testing.T) {
+	t.Setenv("APP_RETRY_MAX_ATTEMPTS", "")
+	t.Setenv("APP_RETRY_JITTER", "true")
+
+	config, err := FromEnv("APP_RETRY")
+	if err != nil {
+		t.Fatalf("FromEnv() error = %v", err)
+	}
+	if config.Jitter != nil {
+		t.Fatalf("FromEnv(): JITTER=true must keep the default jitter")
+	}
+	effective := withDefaults(config)
+	if effective.MaxAttempts != DefaultMaxAttempts || effective.MinDelay != DefaultMinDelay || effective.MaxDelay != DefaultMaxDelay {
+		t.Fatalf("FromEnv() with unset variables = %+v, want the package defaults", effective)
+	}
+}
+
+func TestFromEnvInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string // Фрагменты текста ошибки
+	}{
+		{"attempts not a number", map[string]string{"MAX_ATTEMPTS": "many"}, []string{"APP_MAX_ATTEMPTS", `"many"`}},
+		{"attempts not positive", map[string]string{"MAX_ATTEMPTS": "0"}, []string{"APP_MAX_ATTEMPTS"}},
+		{"bad duration", map[string]string{"MIN_DELAY": "100"}, []string{"APP_MIN_DELAY", `"100"`}},
+		{"negative duration", map[string]string{"MAX_ELAPSED_TIME": "-1s"}, []string{"APP_MAX_ELAPSED_TIME"}},
+		{"min above max", map[string]string{"MIN_DELAY": "10s", "MAX_DELAY": "1s"}, []string{"exceeds max delay"}},
+		{"unknown backoff", map[string]string{"BACKOFF": "quadratic"}, []string{"APP_BACKOFF", "quadratic"}},
+		{"bad jitter", map[string]string{"JITTER": "sometimes"}, []string{"APP_JITTER", `"sometimes"`}},
+		{
+			"all problems reported",
+			map[string]string{"MAX_ATTEMPTS": "x", "MAX_DELAY": "soon", "JITTER": "maybe"},
+			[]string{"APP_MAX_ATTEMPTS", "APP_MAX_DELAY", "APP_JITTER"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv("APP_"+key, value)
+			}
+			config, err := FromEnv("APP")
+			if err == nil {
+				t.Fatalf("FromEnv() = %+v, want an error", config)
+			}
+			for _, fragment := range tt.want {
+				if !strings.Contains(err.Error(), fragment) {
+					t.Fatalf("FromEnv() error = %q, want it to mention %s", err, fragment)
+				}
+			}
+			if config.MaxAttempts != 0 || config.BackoffName != "" {
+				t.Fatalf("FromEnv() = %+v on error, want zero config", config)
+			}
+		})
+	}
+}
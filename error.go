@@ -1,23 +1,116 @@
 package retry
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // HTTPError представляет HTTP ошибку для повторных попыток
 type HTTPError struct {
 	StatusCode int
 	Message    string
+	RetryAfter time.Duration // Задержка из заголовка Retry-After (0 = не задана)
+	Header     http.Header   // Заголовки ответа для классификаторов (может быть nil)
+	Cause      error         // Исходная ошибка клиента (может быть nil)
+}
+
+// NewHTTPError создаёт HTTPError из HTTP ответа, учитывая заголовок Retry-After
+func NewHTTPError(resp *http.Response) *HTTPError {
+	retryAfter, _ := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Message:    http.StatusText(resp.StatusCode),
+		RetryAfter: retryAfter,
+		Header:     resp.Header,
+	}
+}
+
+// WrapHTTPError оборачивает ошибку клиентской библиотеки с известным статус-кодом.
+// errors.Is/As находят исходную ошибку, а Temporary()/Timeout() определяются по статусу.
+func WrapHTTPError(statusCode int, cause error) *HTTPError {
+	message := http.StatusText(statusCode)
+	if cause != nil {
+		message = cause.Error()
+	}
+	return &HTTPError{
+		StatusCode: statusCode,
+		Message:    message,
+		Cause:      cause,
+	}
+}
+
+// RetryOnHeader возвращает функцию для ShouldRetry, разрешающую повтор HTTPError,
+// у которой заголовок name равен value (например, X-Should-Retry: true)
+func RetryOnHeader(name, value string) func(error) bool {
+	return func(err error) bool {
+		var httpErr *HTTPError
+		if !errors.As(err, &httpErr) || httpErr.Header == nil {
+			return false
+		}
+		return httpErr.Header.Get(name) == value
+	}
 }
 
 func (e *HTTPError) Error() string {
 	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
 }
 
+func (e *HTTPError) Unwrap() error {
+	return e.Cause
+}
+
 func (e *HTTPError) Timeout() bool {
-	// 408 Request Timeout - настоящий таймаут
-	return e.StatusCode == 408
+	// 408 Request Timeout, 504 Gateway Timeout и 524 (Cloudflare) - таймауты.
+	// 504 и 524 одновременно попадают и в Temporary() как 5xx.
+	switch e.StatusCode {
+	case 408, 504, 524:
+		return true
+	}
+	return false
 }
 
 func (e *HTTPError) Temporary() bool {
 	// 5xx - ошибки сервера, 429 - слишком много запросов
 	return e.StatusCode >= 500 || e.StatusCode == 429
 }
+
+// DelayHint возвращает задержку из Retry-After, если сервер её указал.
+// При наличии заголовков значение берётся из них, поэтому Retry-After: 0 означает
+// немедленный повтор; отрицательное или некорректное значение оставляет обычный backoff.
+func (e *HTTPError) DelayHint() (time.Duration, bool) {
+	return e.delayHintAt(time.Now())
+}
+
+// delayHintAt вычисляет DelayHint относительно now; WithRetry передаёт время Clock,
+// чтобы HTTP-дата в Retry-After отсчитывалась от него
+func (e *HTTPError) delayHintAt(now time.Time) (time.Duration, bool) {
+	if value := e.Header.Get("Retry-After"); value != "" {
+		return parseRetryAfter(value, now)
+	}
+	return e.RetryAfter, e.RetryAfter > 0
+}
+
+// parseRetryAfter разбирает значение Retry-After: число секунд или HTTP-дату
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+
+	return 0, false
+}
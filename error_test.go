@@ -0,0 +1,382 @@
+package retry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryOnHeader(t *testing.T) {
+	shouldRetry := RetryOnHeader("X-Should-Retry", "true")
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"header matches", &HTTPError{StatusCode: 409, Header: http.Header{"X-Should-Retry": {"true"}}}, true},
+		{"wrapped", fmt.Errorf("call: %w", &HTTPError{StatusCode: 409, Header: http.Header{"X-Should-Retry": {"true"}}}), true},
+		{"other value", &HTTPError{StatusCode: 503, Header: http.Header{"X-Should-Retry": {"false"}}}, false},
+		{"no headers", &HTTPError{StatusCode: 503}, false},
+		{"not an HTTPError", errRetriable, false},
+	}
+	for _, tt := range tests {
+		if got := shouldRetry(tt.err); got != tt.want {
+			t.Fatalf("%s: RetryOnHeader() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := testEpoch
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"0", 0, true},
+		{"5", 5 * time.Second, true},
+		{" 5 ", 5 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"1.5", 0, false},
+		{"", 0, false},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Fatalf("parseRetryAfter(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRetryAfterDelay(t *testing.T) {
+	// Retry-After: 0 — немедленный повтор, некорректное значение оставляет backoff
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"zero", "0", 0},
+		{"positive", "3", 3 * time.Second},
+		{"negative", "-3", 500 * time.Millisecond},
+		{"malformed", "later", 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			err := &HTTPError{StatusCode: 503, Header: http.Header{"Retry-After": {tt.value}}}
+			_, _ = WithRetry(context.Background(), RetryConfig{
+				Clock:       clock,
+				MaxAttempts: 2,
+				MinDelay:    500 * time.Millisecond,
+				MaxDelay:    time.Minute,
+				Jitter:      noJitter,
+			}, "op", failTimes(1, error(err)))
+			if sleeps := clock.Sleeps(); len(sleeps) != 1 || sleeps[0] != tt.want {
+				t.Fatalf("sleeps = %v, want [%s]", sleeps, tt.want)
+			}
+		})
+	}
+}
+
+// timeoutError — net.Error с Timeout(), как таймаут TLS-рукопожатия
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "tls: handshake timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestShouldRetryTLSErrors(t *testing.T) {
+	wrap := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "remote error", Net: "tcp", Err: err}}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"record header", tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}, true},
+		{"record header in url.Error", wrap(tls.RecordHeaderError{Msg: "bad record"}), true},
+		{"handshake timeout", timeoutError{}, true},
+		{"certificate invalid", x509.CertificateInvalidError{Reason: x509.Expired}, false},
+		{"unknown authority in url.Error", wrap(x509.UnknownAuthorityError{}), false},
+		{"hostname mismatch", fmt.Errorf("dial: %w", x509.HostnameError{Host: "example.com", Certificate: &x509.Certificate{}}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldRetryError(tt.err); got != tt.want {
+				t.Fatalf("shouldRetryError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryErrorClassificationCounts(t *testing.T) {
+	tests := []struct {
+		name             string
+		errs             []error // Ошибки попыток по порядку (последняя повторяется)
+		wantRetriable    int
+		wantNonRetriable int
+		wantReason       Reason
+	}{
+		{"all retriable", []error{errRetriable}, 3, 0, AttemptsExhausted},
+		{"retriable then non-retriable", []error{errRetriable, errRetriable, errTest}, 2, 1, NonRetriable},
+		{"non-retriable first", []error{errTest}, 0, 1, NonRetriable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			_, err := WithRetry(context.Background(), RetryConfig{Clock: newFakeClock(), MaxAttempts: 3}, "op",
+				func(context.Context) (int, error) {
+					calls++
+					return 0, tt.errs[min(calls, len(tt.errs))-1]
+				})
+			retryErr := requireReason(t, err, tt.wantReason)
+			if retryErr.RetriableErrors != tt.wantRetriable || retryErr.NonRetriableErrors != tt.wantNonRetriable {
+				t.Fatalf("RetriableErrors = %d, NonRetriableErrors = %d, want %d, %d",
+					retryErr.RetriableErrors, retryErr.NonRetriableErrors, tt.wantRetriable, tt.wantNonRetriable)
+			}
+
+			data, _ := json.Marshal(retryErr)
+			want := fmt.Sprintf(`"retriable_errors":%d,"non_retriable_errors":%d`, tt.wantRetriable, tt.wantNonRetriable)
+			if !strings.Contains(string(data), want) {
+				t.Fatalf("MarshalJSON() = %s, want counts %s", data, want)
+			}
+		})
+	}
+}
+
+func TestWrapHTTPError(t *testing.T) {
+	cause := errors.New("client: quota exceeded")
+	err := fmt.Errorf("call: %w", WrapHTTPError(http.StatusTooManyRequests, cause))
+
+	if !errors.Is(err, cause) {
+		t.Fatalf("errors.Is(%v, cause) = false, want the client error found through HTTPError", err)
+	}
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests || !httpErr.Temporary() {
+		t.Fatalf("errors.As(%v) = %+v, want a temporary HTTPError with status 429", err, httpErr)
+	}
+	if httpErr.Message != cause.Error() {
+		t.Fatalf("Message = %q, want the cause text", httpErr.Message)
+	}
+
+	// Ошибка, найденная через обёртку, повторяется по статусу
+	_, err = WithRetry(context.Background(), RetryConfig{Clock: newFakeClock(), MaxAttempts: 2}, "op",
+		func(context.Context) (int, error) { return 0, err })
+	requireReason(t, err, AttemptsExhausted)
+	if !errors.Is(err, cause) {
+		t.Fatalf("WithRetry() error = %v, want the client error inside", err)
+	}
+}
+
+func TestRetryErrorText(t *testing.T) {
+	first := &HTTPError{StatusCode: 503, Message: "overloaded"}
+	last := &HTTPError{StatusCode: 504, Message: "gateway timeout"}
+	tests := []struct {
+		name string
+		err  *RetryError
+		want string
+	}{
+		{"different errors", &RetryError{Operation: "fetch", Attempts: 3, FirstError: first, LastError: last},
+			"operation 'fetch' failed after 3 attempts (first: HTTP 503: overloaded, last: HTTP 504: gateway timeout)"},
+		{"same text", &RetryError{Operation: "fetch", Attempts: 3, FirstError: first, LastError: &HTTPError{StatusCode: 503, Message: "overloaded"}},
+			"operation 'fetch' failed after 3 attempts: HTTP 503: overloaded"},
+		{"single attempt", &RetryError{Operation: "fetch", Attempts: 1, FirstError: last, LastError: last},
+			"operation 'fetch' failed after 1 attempts: HTTP 504: gateway timeout"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Fatalf("%s: Error() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShouldRetryJoinedErrors(t *testing.T) {
+	// Объединённая ошибка повторяется, если повторяема хотя бы одна составляющая
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"retriable and non-retriable", errors.Join(errTest, errRetriable), true},
+		{"non-retriable and retriable", errors.Join(&HTTPError{StatusCode: 400}, errRetriable), true},
+		{"wrapped join", fmt.Errorf("batch: %w", errors.Join(errTest, fmt.Errorf("item: %w", errRetriable))), true},
+		{"nested join", errors.Join(errTest, errors.Join(context.Canceled, errRetriable)), true},
+		{"all non-retriable", errors.Join(errTest, &HTTPError{StatusCode: 404}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldRetryError(tt.err); got != tt.want {
+				t.Fatalf("shouldRetryError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+
+	op, calls := failing(errors.Join(errTest, errRetriable))
+	_, err := WithRetry(context.Background(), RetryConfig{Clock: newFakeClock(), MaxAttempts: 3}, "op", op)
+	requireReason(t, err, AttemptsExhausted)
+	if *calls != 3 {
+		t.Fatalf("calls = %d, want 3", *calls)
+	}
+}
+
+// envelopeError — собственная итоговая ошибка для проверки ErrorWrapper
+type envelopeError struct {
+	operation string
+	attempts  int
+	err       error
+}
+
+func (e *envelopeError) Error() string {
+	return fmt.Sprintf("%s failed after %d attempts: %v", e.operation, e.attempts, e.err)
+}
+
+func (e *envelopeError) Unwrap() error { return e.err }
+
+func TestErrorWrapper(t *testing.T) {
+	wrapper := func(operation string, attempts int, lastErr error) error {
+		return &envelopeError{operation: operation, attempts: attempts, err: lastErr}
+	}
+	tests := []struct {
+		name         string
+		err          error
+		wantAttempts int
+	}{
+		{"exhausted", errRetriable, 3},
+		{"aborted", fmt.Errorf("decode: %w", errTest), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, _ := failing(tt.err)
+			_, err := WithRetry(context.Background(), RetryConfig{
+				Clock:        newFakeClock(),
+				MaxAttempts:  3,
+				ErrorWrapper: wrapper,
+			}, "fetch", op)
+
+			var envelope *envelopeError
+			if !errors.As(err, &envelope) {
+				t.Fatalf("WithRetry() error = %T %v, want *envelopeError", err, err)
+			}
+			if envelope.operation != "fetch" || envelope.attempts != tt.wantAttempts {
+				t.Fatalf("wrapper got operation %q, attempts %d, want fetch, %d", envelope.operation, envelope.attempts, tt.wantAttempts)
+			}
+			var retryErr *RetryError
+			if errors.As(err, &retryErr) {
+				t.Fatalf("WithRetry() error = %v, want no *RetryError with ErrorWrapper", err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("errors.Is(%v, last error) = false, want Unwrap to reach it", err)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorTimeoutTemporary(t *testing.T) {
+	tests := []struct {
+		status             int
+		timeout, temporary bool
+	}{
+		{400, false, false},
+		{408, true, false},
+		{429, false, true},
+		{500, false, true},
+		{503, false, true},
+		{504, true, true},
+		{524, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.status), func(t *testing.T) {
+			err := &HTTPError{StatusCode: tt.status}
+			if got := err.Timeout(); got != tt.timeout {
+				t.Fatalf("Timeout() = %v, want %v", got, tt.timeout)
+			}
+			if got := err.Temporary(); got != tt.temporary {
+				t.Fatalf("Temporary() = %v, want %v", got, tt.temporary)
+			}
+		})
+	}
+}
+
+func TestRetryErrorJSON(t *testing.T) {
+	decode := func(t *testing.T, clock *fakeClock, op func(context.Context) (int, error)) map[string]any {
+		t.Helper()
+		_, err := WithRetry(context.Background(), RetryConfig{
+			Clock:       clock,
+			MaxAttempts: 3,
+			MinDelay:    time.Second,
+			MaxDelay:    time.Second,
+			Jitter:      noJitter,
+		}, "fetch", op)
+		var retryErr *RetryError
+		if !errors.As(err, &retryErr) {
+			t.Fatalf("WithRetry() error = %v, want *RetryError", err)
+		}
+		data, err := json.Marshal(retryErr)
+		if err != nil {
+			t.Fatalf("json.Marshal() error = %v", err)
+		}
+		var got map[string]any
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("json.Unmarshal(%s) error = %v", data, err)
+		}
+		return got
+	}
+	t.Run("exhausted", func(t *testing.T) {
+		clock := newFakeClock()
+		op, _ := failing(errRetriable)
+		got := decode(t, clock, op)
+		want := map[string]any{
+			"operation":            "fetch",
+			"attempts":             float64(3),
+			"reason":               string(AttemptsExhausted),
+			"last_error":           errRetriable.Error(),
+			"total_elapsed":        "2s",
+			"first_error":          errRetriable.Error(),
+			"retriable_errors":     float64(3),
+			"non_retriable_errors": float64(0),
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("JSON = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("aborted", func(t *testing.T) {
+		clock := newFakeClock()
+		calls := 0
+		got := decode(t, clock, func(context.Context) (int, error) {
+			calls++
+			clock.Advance(250 * time.Millisecond)
+			if calls == 2 {
+				return 0, fmt.Errorf("decode: %w", errTest)
+			}
+			return 0, errRetriable
+		})
+		want := map[string]any{
+			"operation":            "fetch",
+			"attempts":             float64(2),
+			"reason":               string(NonRetriable),
+			"last_error":           "decode: test error",
+			"total_elapsed":        "1.5s",
+			"first_error":          errRetriable.Error(),
+			"retriable_errors":     float64(1),
+			"non_retriable_errors": float64(1),
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("JSON = %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package retry
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// EventType — тип события цикла повторных попыток
+type EventType string
+
+const (
+	EventAttemptStart EventType = "attempt_start" // Начало попытки
+	EventSuccess      EventType = "success"       // Попытка успешна
+	EventFailure      EventType = "failure"       // Попытка завершилась ошибкой
+	EventSleep        EventType = "sleep"         // Запланировано ожидание перед повтором
+	EventGiveUp       EventType = "give_up"       // Попытки прекращены с RetryError
+)
+
+// Event — событие цикла повторных попыток для RetryConfig.Events
+type Event struct {
+	Type      EventType
+	Operation string
+	Attempt   int
+	Delay     time.Duration // Задержка перед повтором (только EventSleep)
+	Err       error         // Ошибка попытки (EventFailure, EventGiveUp)
+	Reason    Reason        // Причина прекращения (только EventGiveUp)
+}
+
+// emitEvent отправляет событие в Events без блокировки: если в канале нет места,
+// событие отбрасывается, чтобы медленный подписчик не тормозил цикл
+func emitEvent(config RetryConfig, event Event) {
+	if config.DebugWriter != nil {
+		writeDebug(config.DebugWriter, event)
+	}
+	if config.Events == nil {
+		return
+	}
+	select {
+	case config.Events <- event:
+	default:
+	}
+}
+
+// writeDebug пишет событие одной читаемой строкой для DebugWriter. Ошибки записи игнорируются.
+func writeDebug(w io.Writer, event Event) {
+	var line string
+	switch event.Type {
+	case EventAttemptStart:
+		line = fmt.Sprintf("attempt %d started", event.Attempt)
+	case EventSuccess:
+		line = fmt.Sprintf("attempt %d succeeded", event.Attempt)
+	case EventFailure:
+		line = fmt.Sprintf("attempt %d failed: %v", event.Attempt, event.Err)
+	case EventSleep:
+		line = fmt.Sprintf("sleeping %s before attempt %d", event.Delay, event.Attempt+1)
+	case EventGiveUp:
+		line = fmt.Sprintf("giving up after %d attempts (%s): %v", event.Attempt, event.Reason, event.Err)
+	default:
+		line = string(event.Type)
+	}
+	_, _ = fmt.Fprintf(w, "retry: %s: %s\n", event.Operation, line)
+}
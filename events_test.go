@@ -0,0 +1,112 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failTimes возвращает операцию, которая failures раз завершается ошибкой err, затем успешна
+func failTimes(failures int, err error) func(context.Context) (int, error) {
+	calls := 0
+	return func(context.Context) (int, error) {
+		calls++
+		if calls <= failures {
+			return 0, err
+		}
+		return calls, nil
+	}
+}
+
+func TestEventsSequence(t *testing.T) {
+	events := make(chan Event, 16)
+	_, err := WithRetry(context.Background(), RetryConfig{
+		Clock:    newFakeClock(),
+		MinDelay: time.Second,
+		MaxDelay: time.Minute,
+		Jitter:   noJitter,
+		Events:   events,
+	}, "op", failTimes(2, errRetriable))
+	if err != nil {
+		t.Fatalf("WithRetry() error = %v", err)
+	}
+	close(events)
+
+	type step struct {
+		typ     EventType
+		attempt int
+		delay   time.Duration
+	}
+	var got []step
+	for e := range events {
+		if e.Operation != "op" {
+			t.Fatalf("event Operation = %q, want %q", e.Operation, "op")
+		}
+		if e.Type == EventFailure && e.Err != errRetriable {
+			t.Fatalf("failure event Err = %v, want %v", e.Err, errRetriable)
+		}
+		got = append(got, step{e.Type, e.Attempt, e.Delay})
+	}
+	want := []step{
+		{EventAttemptStart, 1, 0}, {EventFailure, 1, 0}, {EventSleep, 1, time.Second},
+		{EventAttemptStart, 2, 0}, {EventFailure, 2, 0}, {EventSleep, 2, 2 * time.Second},
+		{EventAttemptStart, 3, 0}, {EventSuccess, 3, 0},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+}
+
+func TestEventsDroppedWhenFull(t *testing.T) {
+	// Канал без читателя не блокирует цикл: лишние события отбрасываются
+	events := make(chan Event, 1)
+	done := make(chan error, 1)
+	go func() {
+		_, err := WithRetry(context.Background(), RetryConfig{Clock: newFakeClock(), Events: events}, "op", failTimes(2, errRetriable))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("WithRetry() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("WithRetry() blocked on a full Events channel")
+	}
+	if e := <-events; e.Type != EventAttemptStart || e.Attempt != 1 {
+		t.Fatalf("first event = %+v, want attempt_start of attempt 1", e)
+	}
+}
+
+func TestDebugWriterFormat(t *testing.T) {
+	// DebugWriter работает независимо от Logger: оба получают записи
+	var out, logs bytes.Buffer
+	_, err := WithRetry(context.Background(), RetryConfig{
+		Clock:       newFakeClock(),
+		MaxAttempts: 2,
+		MinDelay:    time.Second,
+		MaxDelay:    time.Second,
+		Jitter:      noJitter,
+		DebugWriter: &out,
+		Logger:      slog.New(slog.NewTextHandler(&logs, nil)),
+	}, "fetch", failTimes(5, errRetriable))
+	requireReason(t, err, AttemptsExhausted)
+
+	want := "retry: fetch: attempt 1 started\n" +
+		"retry: fetch: attempt 1 failed: HTTP 503: Service Unavailable\n" +
+		"retry: fetch: sleeping 1s before attempt 2\n" +
+		"retry: fetch: attempt 2 started\n" +
+		"retry: fetch: attempt 2 failed: HTTP 503: Service Unavailable\n" +
+		"retry: fetch: giving up after 2 attempts (attempts_exhausted): HTTP 503: Service Unavailable\n"
+	if out.String() != want {
+		t.Fatalf("DebugWriter output:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if !strings.Contains(logs.String(), "attempts exhausted") {
+		t.Fatalf("Logger output without the give-up record:\n%s", logs.String())
+	}
+}
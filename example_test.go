@@ -0,0 +1,73 @@
+package retry_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alfzs/retry"
+)
+
+// steppedClock — фейковые часы, сообщающие через waiting о каждом ожидании цикла
+type steppedClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timer   chan time.Time
+	waiting chan time.Duration
+}
+
+func (c *steppedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *steppedClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	c.timer = make(chan time.Time, 1)
+	timer := c.timer
+	c.mu.Unlock()
+
+	c.waiting <- d // Цикл дошёл до ожидания: тест может сдвигать время
+	return timer
+}
+
+// Advance сдвигает время и срабатывает текущий таймер
+func (c *steppedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.timer <- c.now
+}
+
+func ExampleClock() {
+	clock := &steppedClock{now: time.Unix(0, 0), waiting: make(chan time.Duration)}
+	config := retry.RetryConfig{
+		Clock:       clock,
+		MaxAttempts: 3,
+		MinDelay:    time.Second,
+		MaxDelay:    time.Minute,
+		Jitter:      func(_ int, delay time.Duration) time.Duration { return delay },
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := retry.WithRetry(context.Background(), config, "op", func(context.Context) (int, error) {
+			return 0, &retry.HTTPError{StatusCode: 503}
+		})
+		done <- err
+	}()
+
+	// Время сдвигается только после того, как цикл заблокировался в ожидании
+	for range config.MaxAttempts - 1 {
+		delay := <-clock.waiting
+		fmt.Println("waiting", delay)
+		clock.Advance(delay)
+	}
+	fmt.Println(<-done != nil)
+	// Output:
+	// waiting 1s
+	// waiting 2s
+	// true
+}
@@ -0,0 +1,66 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FallbackError содержит ошибки всех этапов Fallback
+type FallbackError struct {
+	Operation string
+	Errors    []error // Ошибки этапов в порядке выполнения
+}
+
+func (e *FallbackError) Error() string {
+	stages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		stages[i] = fmt.Sprintf("stage %d: %v", i, err)
+	}
+	return fmt.Sprintf("operation '%s' failed at all %d stages: %s",
+		e.Operation, len(e.Errors), strings.Join(stages, "; "))
+}
+
+func (e *FallbackError) Unwrap() []error {
+	return e.Errors
+}
+
+// Fallback последовательно выполняет операции, каждую со своим циклом повторных попыток,
+// и возвращает результат первой успешной. Если все этапы неудачны, возвращается FallbackError.
+// При отмене контекста следующие этапы не запускаются.
+func Fallback[T any](
+	ctx context.Context,
+	config RetryConfig,
+	operationName string,
+	operationFns ...func(context.Context) (T, error),
+) (T, error) {
+	var result T
+	if len(operationFns) == 0 {
+		return result, errors.New("retry: no operations")
+	}
+	for _, fn := range operationFns {
+		if fn == nil {
+			return result, ErrNilOperation
+		}
+	}
+
+	errs := make([]error, 0, len(operationFns))
+
+	for i, operationFn := range operationFns {
+		var err error
+		result, err = WithRetry(ctx, config, fmt.Sprintf("%s[%d]", operationName, i), operationFn)
+		if err == nil {
+			return result, nil
+		}
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		errs = append(errs, err)
+	}
+
+	return result, &FallbackError{
+		Operation: operationName,
+		Errors:    errs,
+	}
+}
@@ -0,0 +1,83 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// stage возвращает этап Fallback, завершающийся результатом value или ошибкой err, и счётчик вызовов
+func stage(value int, err error) (func(context.Context) (int, error), *int) {
+	calls := 0
+	return func(context.Context) (int, error) {
+		calls++
+		return value, err
+	}, &calls
+}
+
+func TestFallbackStages(t *testing.T) {
+	config := RetryConfig{Clock: newFakeClock(), MaxAttempts: 2}
+	for succeeding := range 3 {
+		var fns []func(context.Context) (int, error)
+		var calls []*int
+		for i := range 3 {
+			var err error
+			if i != succeeding {
+				err = errRetriable
+			}
+			fn, n := stage(i+1, err)
+			fns = append(fns, fn)
+			calls = append(calls, n)
+		}
+
+		result, err := Fallback(context.Background(), config, "op", fns...)
+		if err != nil || result != succeeding+1 {
+			t.Fatalf("stage %d succeeds: Fallback() = %d, %v, want %d", succeeding, result, err, succeeding+1)
+		}
+		// Неудачные этапы до успешного исчерпывают свои попытки, следующие не запускаются
+		for i, n := range calls {
+			want := 0
+			switch {
+			case i < succeeding:
+				want = 2
+			case i == succeeding:
+				want = 1
+			}
+			if *n != want {
+				t.Fatalf("stage %d succeeds: stage %d calls = %d, want %d", succeeding, i, *n, want)
+			}
+		}
+	}
+}
+
+func TestFallbackAllFail(t *testing.T) {
+	errPrimary := &HTTPError{StatusCode: 503, Message: "primary"}
+	errReplica := errors.New("replica gone")
+	primary, _ := stage(0, errPrimary)
+	replica, _ := stage(0, errReplica)
+
+	_, err := Fallback(context.Background(), RetryConfig{Clock: newFakeClock(), MaxAttempts: 2}, "op", primary, replica)
+	var fallbackErr *FallbackError
+	if !errors.As(err, &fallbackErr) || len(fallbackErr.Errors) != 2 {
+		t.Fatalf("Fallback() error = %v, want FallbackError with both stages", err)
+	}
+	if !errors.Is(err, errPrimary) || !errors.Is(err, errReplica) {
+		t.Fatalf("Fallback() error = %v, want the errors of every stage inside", err)
+	}
+	requireReason(t, fallbackErr.Errors[0], AttemptsExhausted)
+	requireReason(t, fallbackErr.Errors[1], NonRetriable)
+	if !strings.Contains(err.Error(), "stage 1") {
+		t.Fatalf("Error() = %q, want the stages listed", err)
+	}
+}
+
+func TestFallbackNoOperations(t *testing.T) {
+	if _, err := Fallback[int](context.Background(), RetryConfig{}, "op"); err == nil {
+		t.Fatal("Fallback() without operations error = nil, want an error")
+	}
+	op, calls := stage(1, nil)
+	if _, err := Fallback(context.Background(), RetryConfig{}, "op", op, nil); !errors.Is(err, ErrNilOperation) || *calls != 0 {
+		t.Fatalf("Fallback() with a nil operation error = %v after %d calls, want ErrNilOperation before any stage", err, *calls)
+	}
+}
@@ -0,0 +1,95 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WeightedOperation — операция с весом предпочтения для FirstSuccessWeighted
+type WeightedOperation[T any] struct {
+	Weight    int                              // Вес (<= 0 считается равным 1)
+	Operation func(context.Context) (T, error) // Операция
+}
+
+// FirstSuccess запускает операции одновременно, каждую со своим циклом повторных попыток,
+// и возвращает первый успешный результат, отменяя остальные. Если все операции неудачны,
+// возвращается объединение их ошибок (errors.Join).
+func FirstSuccess[T any](
+	ctx context.Context,
+	config RetryConfig,
+	operationName string,
+	operationFns ...func(context.Context) (T, error),
+) (T, error) {
+	ops := make([]WeightedOperation[T], len(operationFns))
+	for i, fn := range operationFns {
+		ops[i] = WeightedOperation[T]{Weight: 1, Operation: fn}
+	}
+	return FirstSuccessWeighted(ctx, config, operationName, ops...)
+}
+
+// FirstSuccessWeighted работает как FirstSuccess, но учитывает веса операций.
+// Операция с наибольшим весом получает MaxAttempts попыток и исходные задержки.
+// Операция с весом w получает MaxAttempts*w/maxWeight попыток (не меньше одной),
+// а её MinDelay и MaxDelay увеличиваются в maxWeight/w раз, то есть менее
+// предпочтительные операции ретраятся реже и отступают сильнее.
+func FirstSuccessWeighted[T any](
+	ctx context.Context,
+	config RetryConfig,
+	operationName string,
+	ops ...WeightedOperation[T],
+) (T, error) {
+	var zero T
+	if len(ops) == 0 {
+		return zero, errors.New("retry: no operations")
+	}
+
+	config = withDefaults(config)
+	maxWeight := 1
+	for _, op := range ops {
+		maxWeight = max(maxWeight, op.Weight)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type opResult struct {
+		index int
+		value T
+		err   error
+	}
+
+	results := make(chan opResult, len(ops))
+	for i, op := range ops {
+		opConfig := weightedConfig(config, max(op.Weight, 1), maxWeight)
+		go func() {
+			value, err := WithRetry(ctx, opConfig, fmt.Sprintf("%s[%d]", operationName, i), op.Operation)
+			results <- opResult{index: i, value: value, err: err}
+		}()
+	}
+
+	errs := make([]error, len(ops))
+	for range ops {
+		r := <-results
+		if r.err == nil {
+			return r.value, nil
+		}
+		errs[r.index] = r.err
+	}
+
+	return zero, errors.Join(errs...)
+}
+
+// weightedConfig масштабирует попытки и задержки конфигурации по весу операции
+func weightedConfig(config RetryConfig, weight, maxWeight int) RetryConfig {
+	if weight == maxWeight {
+		return config
+	}
+
+	ratio := float64(maxWeight) / float64(weight)
+	config.MaxAttempts = max(1, config.MaxAttempts*weight/maxWeight)
+	config.MinDelay = time.Duration(float64(config.MinDelay) * ratio)
+	config.MaxDelay = time.Duration(float64(config.MaxDelay) * ratio)
+	return config
+}
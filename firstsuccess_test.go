@@ -0,0 +1,84 @@
+package retry
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFirstSuccessWeightedAttempts(t *testing.T) {
+	// Основная операция с наибольшим весом получает все MaxAttempts попыток,
+	// запасная с весом в четыре раза меньше — четверть
+	var primary, fallback atomic.Int32
+	_, err := FirstSuccessWeighted(context.Background(), RetryConfig{
+		Clock:       newFakeClock(),
+		MaxAttempts: 8,
+		MinDelay:    time.Second,
+		MaxDelay:    time.Minute,
+	}, "op",
+		WeightedOperation[int]{Weight: 4, Operation: func(context.Context) (int, error) {
+			primary.Add(1)
+			return 0, errRetriable
+		}},
+		WeightedOperation[int]{Weight: 1, Operation: func(context.Context) (int, error) {
+			fallback.Add(1)
+			return 0, errRetriable
+		}},
+	)
+
+	if err == nil {
+		t.Fatalf("FirstSuccessWeighted() error = nil, want joined errors")
+	}
+	if got := primary.Load(); got != 8 {
+		t.Fatalf("primary attempts = %d, want 8", got)
+	}
+	if got := fallback.Load(); got != 2 {
+		t.Fatalf("fallback attempts = %d, want 2", got)
+	}
+}
+
+func TestFirstSuccessWeightedPrefersPrimary(t *testing.T) {
+	// Основная операция успевает повториться, а запасная исчерпывает свою единственную попытку
+	var primary atomic.Int32
+	got, err := FirstSuccessWeighted(context.Background(), RetryConfig{
+		Clock:       newFakeClock(),
+		MaxAttempts: 3,
+		MinDelay:    time.Second,
+		MaxDelay:    time.Minute,
+	}, "op",
+		WeightedOperation[string]{Weight: 1, Operation: func(context.Context) (string, error) {
+			return "", errRetriable
+		}},
+		WeightedOperation[string]{Weight: 3, Operation: func(context.Context) (string, error) {
+			if primary.Add(1) < 3 {
+				return "", errRetriable
+			}
+			return "primary", nil
+		}},
+	)
+
+	if err != nil || got != "primary" {
+		t.Fatalf("FirstSuccessWeighted() = %q, %v, want primary, nil", got, err)
+	}
+}
+
+func TestWeightedConfig(t *testing.T) {
+	config := RetryConfig{MaxAttempts: 8, MinDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	if got := weightedConfig(config, 4, 4); got.MaxAttempts != 8 || got.MinDelay != time.Second || got.MaxDelay != 10*time.Second {
+		t.Fatalf("weightedConfig(4, 4) = %+v, want the config unchanged", got)
+	}
+
+	// Менее предпочтительная операция получает меньше попыток и отступает сильнее
+	got := weightedConfig(config, 1, 4)
+	if got.MaxAttempts != 2 || got.MinDelay != 4*time.Second || got.MaxDelay != 40*time.Second {
+		t.Fatalf("weightedConfig(1, 4) = attempts %d, delays %s..%s, want 2, 4s..40s",
+			got.MaxAttempts, got.MinDelay, got.MaxDelay)
+	}
+
+	// Попыток не меньше одной при любом соотношении весов
+	if got := weightedConfig(config, 1, 100); got.MaxAttempts != 1 {
+		t.Fatalf("weightedConfig(1, 100).MaxAttempts = %d, want 1", got.MaxAttempts)
+	}
+}
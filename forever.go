@@ -0,0 +1,145 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// ForeverConfig содержит параметры цикла Forever
+type ForeverConfig struct {
+	RetryConfig // Параметры backoff и классификации ошибок
+
+	// OnHealthy вызывается один раз за серию успехов, когда подряд выполнено
+	// SuccessThreshold успешных запусков (по умолчанию 1). Любая ошибка сбрасывает серию.
+	OnHealthy        func()
+	SuccessThreshold int
+
+	// DecayOnSuccess задаёт, во сколько раз уменьшается текущая задержка после успеха
+	// (например, 0.5 — вдвое), не опускаясь ниже MinDelay. Значения вне (0, 1) означают
+	// полный сброс к MinDelay после первого же успеха.
+	DecayOnSuccess float64
+
+	// ResetAfterSuccesses задаёт, сколько успехов подряд нужно, чтобы задержка начала
+	// возвращаться к MinDelay (по умолчанию 1); до этого она удерживается. Гистерезис защищает
+	// от частого сброса backoff, когда зависимость то работает, то нет. В отличие от
+	// SuccessThreshold, влияет только на задержку.
+	ResetAfterSuccesses int
+}
+
+// Forever выполняет операцию в цикле до отмены контекста (например, для reconciler).
+// После успешного запуска следующий выполняется через MinDelay, после повторяемой ошибки —
+// с экспоненциальным backoff. MaxAttempts не учитывается. Неповторяемая ошибка завершает
+// цикл и возвращается; при отмене контекста возвращается ctx.Err().
+func Forever(
+	ctx context.Context,
+	foreverConfig ForeverConfig,
+	operationName string,
+	operationFn func(context.Context) error,
+) error {
+	if operationFn == nil {
+		return ErrNilOperation
+	}
+
+	config := withDefaults(foreverConfig.RetryConfig)
+	threshold := max(foreverConfig.SuccessThreshold, 1)
+	resetAfter := max(foreverConfig.ResetAfterSuccesses, 1)
+
+	decay := foreverConfig.DecayOnSuccess
+	if decay <= 0 || decay >= 1 {
+		decay = 0
+	}
+
+	failures := 0
+	successes := 0
+	current := config.MinDelay
+	var failingSince time.Time // Начало текущей серии неудач для TimeBasedBackoff
+	for {
+		err := operationFn(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		delay := config.MinDelay
+		if err != nil {
+			retriable, multiplier := classify(ctx, config, err)
+			if !retriable {
+				logAttrs(ctx, config, slog.LevelWarn, "Forever loop stopped due to non-retriable error",
+					slog.String("operation", operationName),
+					slog.Any("error", err))
+				return err
+			}
+
+			failures++
+			if failures == 1 {
+				failingSince = config.Clock.Now()
+			}
+			successes = 0
+			delay = nextDelay(config, failures, config.Clock.Now().Sub(failingSince), err, multiplier)
+			current = delay
+			if config.OnRetry != nil {
+				config.OnRetry(failures, -1, delay, err)
+			}
+
+			logAttrs(ctx, config, slog.LevelError, "Operation failed, will retry",
+				slog.String("operation", operationName),
+				slog.Int("failures", failures),
+				slog.Any("error", err))
+		} else {
+			// До ResetAfterSuccesses успехов подряд задержка удерживается. При затухании
+			// она уменьшается постепенно, а счётчик неудач опускается вслед за ней: следующая
+			// неудача продолжает backoff с шага над current, а не с прежнего пика
+			successes++
+			if successes >= resetAfter {
+				current = max(time.Duration(float64(current)*decay), config.MinDelay)
+				for failures > 1 && config.NextDelay(failures+1) > current {
+					failures--
+				}
+			}
+			delay = current
+			if current == config.MinDelay && failures > 0 {
+				logAttrs(ctx, config, slog.LevelInfo, "Operation recovered",
+					slog.String("operation", operationName),
+					slog.Int("failures", failures))
+				failures = 0
+			}
+
+			if successes == threshold && foreverConfig.OnHealthy != nil {
+				foreverConfig.OnHealthy()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-config.Clock.After(delay):
+		case <-config.Wake:
+		}
+	}
+}
+
+// StartForever запускает Forever в фоне. stop отменяет цикл и ждёт его завершения;
+// errs получает неповторяемую ошибку, завершившую цикл, и закрывается после остановки.
+func StartForever(
+	config ForeverConfig,
+	operationName string,
+	operationFn func(context.Context) error,
+) (stop func(), errs <-chan error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer close(errCh)
+		if err := Forever(ctx, config, operationName, operationFn); err != nil && !errors.Is(err, context.Canceled) {
+			errCh <- err
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}, errCh
+}
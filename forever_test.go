@@ -0,0 +1,176 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// foreverSleeps запускает Forever с фейковыми часами по сценарию outcomes
+// (true — успех) и возвращает задержки после каждого запуска. RetryConfig из config заменяется.
+func foreverSleeps(t *testing.T, config ForeverConfig, outcomes ...bool) []time.Duration {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := newFakeClock()
+	run := 0
+	config.RetryConfig = RetryConfig{
+		Clock:    clock,
+		MinDelay: time.Second,
+		MaxDelay: time.Hour,
+		Jitter:   noJitter,
+	}
+	err := Forever(ctx, config, "op", func(context.Context) error {
+		run++
+		if run > len(outcomes) {
+			cancel()
+			return nil
+		}
+		if outcomes[run-1] {
+			return nil
+		}
+		return errRetriable
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Forever() error = %v, want context.Canceled", err)
+	}
+	return clock.Sleeps()
+}
+
+func TestForeverDecay(t *testing.T) {
+	const ok, fail = true, false
+	second := time.Second
+
+	tests := []struct {
+		name     string
+		decay    float64
+		outcomes []bool
+		want     []time.Duration
+	}{
+		// После затухания до 4s следующая неудача продолжает backoff с 8s, а не с 16s
+		{"gradual", 0.5, []bool{fail, fail, fail, fail, ok, fail},
+			[]time.Duration{1 * second, 2 * second, 4 * second, 8 * second, 4 * second, 8 * second}},
+		{"gradual twice", 0.5, []bool{fail, fail, fail, fail, ok, ok, fail},
+			[]time.Duration{1 * second, 2 * second, 4 * second, 8 * second, 4 * second, 2 * second, 4 * second}},
+		{"gradual to MinDelay", 0.5, []bool{fail, fail, ok, ok, fail},
+			[]time.Duration{1 * second, 2 * second, 1 * second, 1 * second, 1 * second}},
+		{"full reset", 0, []bool{fail, fail, fail, fail, ok, fail},
+			[]time.Duration{1 * second, 2 * second, 4 * second, 8 * second, 1 * second, 1 * second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := foreverSleeps(t, ForeverConfig{DecayOnSuccess: tt.decay}, tt.outcomes...); !slices.Equal(got, tt.want) {
+				t.Fatalf("sleeps = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForeverResetAfterSuccesses(t *testing.T) {
+	const ok, fail = true, false
+	second := time.Second
+	flapping := []bool{fail, fail, fail, ok, fail, ok, fail, ok}
+
+	tests := []struct {
+		name     string
+		config   ForeverConfig
+		outcomes []bool
+		want     []time.Duration
+	}{
+		// Без гистерезиса каждый успех сбрасывает backoff, и при флаппинге повторы идут с MinDelay
+		{"reset on every success", ForeverConfig{}, flapping,
+			[]time.Duration{1 * second, 2 * second, 4 * second, 1 * second, 1 * second, 1 * second, 1 * second, 1 * second}},
+		// Одиночные успехи удерживают задержку, backoff продолжает расти
+		{"hysteresis under flapping", ForeverConfig{ResetAfterSuccesses: 2}, flapping,
+			[]time.Duration{1 * second, 2 * second, 4 * second, 4 * second, 8 * second, 8 * second, 16 * second, 16 * second}},
+		{"reset after two successes", ForeverConfig{ResetAfterSuccesses: 2}, []bool{fail, fail, fail, ok, fail, ok, ok, fail},
+			[]time.Duration{1 * second, 2 * second, 4 * second, 4 * second, 8 * second, 8 * second, 1 * second, 1 * second}},
+		{"with decay", ForeverConfig{ResetAfterSuccesses: 2, DecayOnSuccess: 0.5}, []bool{fail, fail, fail, ok, ok, ok, fail},
+			[]time.Duration{1 * second, 2 * second, 4 * second, 4 * second, 2 * second, 1 * second, 1 * second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := foreverSleeps(t, tt.config, tt.outcomes...); !slices.Equal(got, tt.want) {
+				t.Fatalf("sleeps = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartForeverStop(t *testing.T) {
+	started := make(chan struct{}, 1)
+	stop, errs := StartForever(ForeverConfig{RetryConfig: RetryConfig{MinDelay: time.Hour}}, "op",
+		func(context.Context) error {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			return nil
+		})
+	<-started
+
+	// Цикл ждёт час до следующего запуска, но stop прерывает ожидание сразу
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop() did not return promptly")
+	}
+	if err, ok := <-errs; ok {
+		t.Fatalf("errs received %v after stop, want it closed without an error", err)
+	}
+}
+
+func TestStartForeverNonRetriable(t *testing.T) {
+	runs := 0
+	stop, errs := StartForever(ForeverConfig{RetryConfig: RetryConfig{Clock: newFakeClock()}}, "op",
+		func(context.Context) error {
+			runs++
+			if runs == 3 {
+				return errTest
+			}
+			return errRetriable
+		})
+	defer stop()
+
+	if err := <-errs; !errors.Is(err, errTest) {
+		t.Fatalf("errs received %v, want the non-retriable error", err)
+	}
+	if _, ok := <-errs; ok {
+		t.Fatal("errs not closed after the loop stopped")
+	}
+	if runs != 3 {
+		t.Fatalf("runs = %d, want the loop to stop on the third", runs)
+	}
+}
+
+func TestForeverSuccessThreshold(t *testing.T) {
+	const ok, fail = true, false
+	tests := []struct {
+		name     string
+		outcomes []bool
+		want     int // Вызовы OnHealthy
+	}{
+		{"intermittent never reaches threshold", []bool{ok, fail, ok, fail, fail, ok, fail, ok}, 0},
+		{"once per series", []bool{fail, ok, ok, ok, ok}, 1},
+		{"failure resets the series", []bool{ok, ok, fail, ok, fail, ok, ok}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			healthy := 0
+			foreverSleeps(t, ForeverConfig{
+				SuccessThreshold: 2,
+				OnHealthy:        func() { healthy++ },
+			}, tt.outcomes...)
+			if healthy != tt.want {
+				t.Fatalf("OnHealthy called %d times, want %d", healthy, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+module github.com/alfzs/retry/grpcretry
+
+go 1.24.3
+
+require (
+	github.com/alfzs/retry v1.0.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f
+	google.golang.org/grpc v1.71.0
+	google.golang.org/protobuf v1.36.4
+)
+
+require (
+	github.com/alfzs/backoff v1.0.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+)
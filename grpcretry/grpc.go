@@ -0,0 +1,111 @@
+// Package grpcretry классифицирует ошибки gRPC для retry с учётом server pushback:
+// деталей статуса RetryInfo и трейлера grpc-retry-pushback-ms.
+// Зависимость от gRPC изолирована в этом модуле.
+package grpcretry
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/alfzs/retry"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// PushbackTrailer — трейлер server pushback из дизайна повторов gRPC: задержка в миллисекундах,
+// отрицательное или некорректное значение запрещает повтор
+const PushbackTrailer = "grpc-retry-pushback-ms"
+
+// Error — ошибка gRPC с решением сервера о повторе. Реализует retry.DelayHinter,
+// поэтому WithRetry выдерживает указанную сервером задержку.
+type Error struct {
+	Err      error
+	Delay    time.Duration // Задержка, запрошенная сервером
+	HasDelay bool          // Сервер указал задержку
+	Refused  bool          // Сервер запретил повтор через pushback
+}
+
+var _ retry.DelayHinter = (*Error)(nil)
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) DelayHint() (time.Duration, bool) {
+	return e.Delay, e.HasDelay
+}
+
+// Wrap дополняет ошибку вызова решением сервера: задержкой из детали RetryInfo статуса
+// или из трейлера PushbackTrailer (трейлер можно получить опцией grpc.Trailer; nil — без трейлера).
+// Если сервер ничего не указал, ошибка возвращается без изменений.
+//
+//	var trailer metadata.MD
+//	resp, err := client.Get(ctx, req, grpc.Trailer(&trailer))
+//	return resp, grpcretry.Wrap(err, trailer)
+func Wrap(err error, trailer metadata.MD) error {
+	if err == nil {
+		return nil
+	}
+
+	if values := trailer.Get(PushbackTrailer); len(values) > 0 {
+		ms, parseErr := strconv.Atoi(values[0])
+		if parseErr != nil || ms < 0 {
+			return &Error{Err: err, Refused: true}
+		}
+		return &Error{Err: err, Delay: time.Duration(ms) * time.Millisecond, HasDelay: true}
+	}
+
+	if delay, ok := retryInfoDelay(err); ok {
+		return &Error{Err: err, Delay: delay, HasDelay: true}
+	}
+	return err
+}
+
+// ShouldRetry — классификатор ошибок gRPC для RetryConfig.ShouldRetry. Ошибка с задержкой
+// от сервера (RetryInfo или pushback) повторяется, запрет pushback — нет; остальные
+// повторяются по коду статуса: Unavailable, ResourceExhausted и Aborted.
+func ShouldRetry(err error) bool {
+	var grpcErr *Error
+	if errors.As(err, &grpcErr) {
+		if grpcErr.Refused {
+			return false
+		}
+		if grpcErr.HasDelay {
+			return true
+		}
+	}
+	if _, ok := retryInfoDelay(err); ok {
+		return true
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch st.Code() {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// retryInfoDelay возвращает задержку из детали RetryInfo статуса ошибки
+func retryInfoDelay(err error) (time.Duration, bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return 0, false
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,165 @@
+package grpcretry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alfzs/retry"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// recordingClock — часы, в которых ожидание проходит мгновенно; задержки сохраняются
+type recordingClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *recordingClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *recordingClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.sleeps = append(c.sleeps, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// retryInfoError — ошибка статуса code с деталью RetryInfo, запрашивающей задержку delay
+func retryInfoError(t *testing.T, code codes.Code, delay time.Duration) error {
+	t.Helper()
+	st, err := status.New(code, "try later").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+	if err != nil {
+		t.Fatalf("WithDetails() error = %v", err)
+	}
+	return st.Err()
+}
+
+func TestWrap(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	tests := []struct {
+		name      string
+		err       error
+		trailer   metadata.MD
+		wantDelay time.Duration
+		hasDelay  bool
+		refused   bool
+	}{
+		{"RetryInfo detail", retryInfoError(t, codes.ResourceExhausted, 3*time.Second), nil, 3 * time.Second, true, false},
+		{"pushback trailer", unavailable, metadata.Pairs(PushbackTrailer, "1500"), 1500 * time.Millisecond, true, false},
+		{"trailer wins over RetryInfo", retryInfoError(t, codes.Unavailable, 3*time.Second), metadata.Pairs(PushbackTrailer, "200"), 200 * time.Millisecond, true, false},
+		{"negative pushback refuses", unavailable, metadata.Pairs(PushbackTrailer, "-1"), 0, false, true},
+		{"malformed pushback refuses", unavailable, metadata.Pairs(PushbackTrailer, "soon"), 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped := Wrap(tt.err, tt.trailer)
+			var grpcErr *Error
+			if !errors.As(wrapped, &grpcErr) {
+				t.Fatalf("Wrap() = %v, want *Error", wrapped)
+			}
+			if delay, ok := grpcErr.DelayHint(); delay != tt.wantDelay || ok != tt.hasDelay || grpcErr.Refused != tt.refused {
+				t.Fatalf("Wrap() = delay %s (%t), refused %t, want %s (%t), %t",
+					delay, ok, grpcErr.Refused, tt.wantDelay, tt.hasDelay, tt.refused)
+			}
+			if !errors.Is(wrapped, tt.err) {
+				t.Fatalf("Wrap() = %v, want the original error in the chain", wrapped)
+			}
+			if got := ShouldRetry(wrapped); got != !tt.refused {
+				t.Fatalf("ShouldRetry(Wrap()) = %t, want %t", got, !tt.refused)
+			}
+		})
+	}
+
+	// Без решения сервера ошибка не меняется, nil остаётся nil
+	if Wrap(unavailable, metadata.MD{}) != unavailable || Wrap(nil, metadata.Pairs(PushbackTrailer, "10")) != nil {
+		t.Fatal("Wrap() changed an error without server pushback")
+	}
+}
+
+func TestShouldRetryCodes(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{status.Error(codes.Unavailable, ""), true},
+		{status.Error(codes.ResourceExhausted, ""), true},
+		{status.Error(codes.Aborted, ""), true},
+		{status.Error(codes.InvalidArgument, ""), false},
+		{status.Error(codes.NotFound, ""), false},
+		{retryInfoError(t, codes.FailedPrecondition, time.Second), true}, // RetryInfo разрешает повтор
+		{errors.New("not a status"), false},
+	}
+	for _, tt := range tests {
+		if got := ShouldRetry(tt.err); got != tt.want {
+			t.Fatalf("ShouldRetry(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWithRetryHonorsServerDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     func() error
+		wantGap time.Duration
+	}{
+		{"RetryInfo", func() error { return Wrap(retryInfoError(t, codes.Unavailable, 2*time.Second), nil) }, 2 * time.Second},
+		{"pushback", func() error {
+			return Wrap(status.Error(codes.Unavailable, ""), metadata.Pairs(PushbackTrailer, "750"))
+		}, 750 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &recordingClock{}
+			calls := 0
+			_, err := retry.WithRetry(context.Background(), retry.RetryConfig{
+				Clock:       clock,
+				MaxAttempts: 3,
+				MinDelay:    100 * time.Millisecond,
+				MaxDelay:    10 * time.Second,
+				ShouldRetry: ShouldRetry,
+			}, "op", func(context.Context) (int, error) {
+				calls++
+				if calls < 3 {
+					return 0, tt.err()
+				}
+				return calls, nil
+			})
+			if err != nil {
+				t.Fatalf("WithRetry() error = %v", err)
+			}
+			if len(clock.sleeps) != 2 {
+				t.Fatalf("sleeps = %v, want 2", clock.sleeps)
+			}
+			for i, sleep := range clock.sleeps {
+				if sleep != tt.wantGap {
+					t.Fatalf("sleep %d = %s, want the server delay %s", i+1, sleep, tt.wantGap)
+				}
+			}
+		})
+	}
+
+	// Запрет pushback прекращает попытки
+	clock := &recordingClock{}
+	_, err := retry.WithRetry(context.Background(), retry.RetryConfig{Clock: clock, MaxAttempts: 3, ShouldRetry: ShouldRetry},
+		"op", func(context.Context) (int, error) {
+			return 0, Wrap(status.Error(codes.Unavailable, ""), metadata.Pairs(PushbackTrailer, "-1"))
+		})
+	var retryErr *retry.RetryError
+	if !errors.As(err, &retryErr) || retryErr.Reason != retry.NonRetriable || len(clock.sleeps) != 0 {
+		t.Fatalf("WithRetry() error = %v after %d sleeps, want %s without sleeping", err, len(clock.sleeps), retry.NonRetriable)
+	}
+}
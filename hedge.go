@@ -0,0 +1,97 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// HedgeConfig содержит параметры Hedge
+type HedgeConfig struct {
+	RetryConfig // Параметры повторных попыток для попытки целиком
+
+	// HedgeDelay — время ожидания ответа, после которого запускается ещё одна копия операции
+	HedgeDelay time.Duration
+	// MaxHedges — число дополнительных копий за попытку (0 = без хеджирования)
+	MaxHedges int
+	// MaxConcurrentHedges ограничивает число одновременно выполняющихся копий, включая
+	// исходную (0 = без ограничения). При достижении лимита новая копия запускается только
+	// после завершения одной из выполняющихся, что ограничивает рост нагрузки.
+	MaxConcurrentHedges int
+}
+
+// Hedge выполняет операцию с повторными попытками, где каждая попытка хеджируется:
+// если ответа нет дольше HedgeDelay, параллельно запускается ещё одна копия (не более
+// MaxHedges). Возвращается первый успешный результат, остальные копии отменяются.
+// Попытка неудачна, когда завершились с ошибкой все запущенные копии.
+func Hedge[T any](
+	ctx context.Context,
+	config HedgeConfig,
+	operationName string,
+	operationFn func(context.Context) (T, error),
+) (T, error) {
+	if operationFn == nil {
+		var zero T
+		return zero, ErrNilOperation
+	}
+
+	clock := withDefaults(config.RetryConfig).Clock
+	return WithRetry(ctx, config.RetryConfig, operationName, func(ctx context.Context) (T, error) {
+		return hedgeAttempt(ctx, config, clock, operationFn)
+	})
+}
+
+// hedgeAttempt выполняет одну хеджированную попытку
+func hedgeAttempt[T any](
+	ctx context.Context,
+	config HedgeConfig,
+	clock Clock,
+	operationFn func(context.Context) (T, error),
+) (T, error) {
+	var zero T
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	copies := max(config.MaxHedges, 0) + 1
+	limit := config.MaxConcurrentHedges
+	if limit <= 0 || limit > copies {
+		limit = copies
+	}
+
+	// Буфер на все копии: отменённые копии завершаются, даже если результат уже не нужен
+	results := make(chan Result[T], copies)
+	launched, inFlight := 0, 0
+	launch := func() {
+		launched++
+		inFlight++
+		go func() {
+			value, err := operationFn(ctx)
+			results <- Result[T]{Value: value, Err: err}
+		}()
+	}
+
+	launch()
+	var errs []error
+	for {
+		var hedge <-chan time.Time
+		if config.HedgeDelay > 0 && launched < copies && inFlight < limit {
+			hedge = clock.After(config.HedgeDelay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		case <-hedge:
+			launch()
+		case r := <-results:
+			inFlight--
+			if r.Err == nil {
+				return r.Value, nil
+			}
+			errs = append(errs, r.Err)
+			if inFlight == 0 {
+				return zero, errors.Join(errs...)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package retry
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgeMaxConcurrentHedges(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	started := make(chan struct{}, 6)
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := Hedge(context.Background(), HedgeConfig{
+			RetryConfig:         RetryConfig{MaxAttempts: 1, Clock: newFakeClock()},
+			HedgeDelay:          time.Millisecond,
+			MaxHedges:           5,
+			MaxConcurrentHedges: 2,
+		}, "op", func(ctx context.Context) (int, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			started <- struct{}{}
+			<-release
+			return 0, errRetriable
+		})
+		done <- err
+	}()
+
+	// Таймер хеджирования срабатывает сразу, но копий одновременно не больше лимита:
+	// следующая копия запускается только после завершения одной из выполняющихся
+	<-started
+	<-started
+	for range 4 {
+		release <- struct{}{}
+		<-started
+	}
+	release <- struct{}{}
+	release <- struct{}{}
+	requireReason(t, <-done, AttemptsExhausted)
+
+	if got := peak.Load(); got != 2 {
+		t.Fatalf("peak in-flight copies = %d, want 2", got)
+	}
+	if len(started) != 0 {
+		t.Fatalf("launched more than 1 + MaxHedges = 6 copies")
+	}
+}
+
+func TestHedgeFirstSuccessWins(t *testing.T) {
+	// Первая копия зависает, хедж отвечает сразу; зависшая копия отменяется
+	var calls atomic.Int32
+	canceled := make(chan struct{})
+	result, err := Hedge(context.Background(), HedgeConfig{
+		HedgeDelay: time.Millisecond,
+		MaxHedges:  1,
+	}, "op", func(ctx context.Context) (int, error) {
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			close(canceled)
+			return 0, ctx.Err()
+		}
+		return 2, nil
+	})
+	if err != nil || result != 2 {
+		t.Fatalf("Hedge() = %d, %v, want the hedge result 2", result, err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("slow copy was not canceled")
+	}
+}
@@ -0,0 +1,74 @@
+package retry
+
+import (
+	"context"
+	"sync"
+)
+
+// gateKeyKey — ключ контекста для ключа HostGate
+type gateKeyKey struct{}
+
+// WithGateKey возвращает контекст с ключом для HostGate (например, хостом запроса)
+func WithGateKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, gateKeyKey{}, key)
+}
+
+// GateKeyFrom возвращает ключ HostGate из контекста
+func GateKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(gateKeyKey{}).(string)
+	return key
+}
+
+// KeyedLimit возвращает HostGate, допускающий не более n одновременных попыток на ключ:
+// попытки к одному хосту ограничиваются, а к разным выполняются параллельно.
+// Слоты ключа удаляются, когда у него не остаётся ни занятых, ни ожидающих попыток,
+// поэтому число ключей (например, хостов) со временем не накапливается.
+func KeyedLimit(n int) func(ctx context.Context, key string) (func(), error) {
+	limit := &keyedLimit{n: max(n, 1), slots: make(map[string]*keyedSlots)}
+	return limit.acquire
+}
+
+// keyedLimit хранит слоты по ключам со счётчиком использующих их попыток
+type keyedLimit struct {
+	n     int
+	mu    sync.Mutex
+	slots map[string]*keyedSlots
+}
+
+// keyedSlots — слоты одного ключа и число попыток, занявших или ожидающих их
+type keyedSlots struct {
+	ch   chan struct{}
+	refs int
+}
+
+func (l *keyedLimit) acquire(ctx context.Context, key string) (func(), error) {
+	l.mu.Lock()
+	slots, ok := l.slots[key]
+	if !ok {
+		slots = &keyedSlots{ch: make(chan struct{}, l.n)}
+		l.slots[key] = slots
+	}
+	slots.refs++
+	l.mu.Unlock()
+
+	select {
+	case slots.ch <- struct{}{}:
+		return func() {
+			<-slots.ch
+			l.unref(key, slots)
+		}, nil
+	case <-ctx.Done():
+		l.unref(key, slots)
+		return nil, ctx.Err()
+	}
+}
+
+// unref снимает попытку со слотов ключа и удаляет их, если попыток не осталось
+func (l *keyedLimit) unref(key string, slots *keyedSlots) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots.refs--
+	if slots.refs == 0 {
+		delete(l.slots, key)
+	}
+}
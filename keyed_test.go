@@ -0,0 +1,88 @@
+package retry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestKeyedLimitPrunesIdleKeys(t *testing.T) {
+	limit := &keyedLimit{n: 1, slots: make(map[string]*keyedSlots)}
+	ctx := context.Background()
+
+	releaseA, err := limit.acquire(ctx, "a")
+	if err != nil {
+		t.Fatalf("acquire(a) error = %v", err)
+	}
+	releaseB, err := limit.acquire(ctx, "b")
+	if err != nil {
+		t.Fatalf("acquire(b) error = %v, keys must not block each other", err)
+	}
+
+	// Вторая попытка к "a" ждёт слот и отменяется по контексту
+	if _, err := limit.acquire(ctxWithTimeout(t, 10*time.Millisecond), "a"); err == nil {
+		t.Fatalf("acquire(a) while busy error = nil, want context error")
+	}
+	if refs := limit.slots["a"].refs; refs != 1 {
+		t.Fatalf("refs(a) = %d after canceled wait, want 1", refs)
+	}
+
+	releaseA()
+	if _, ok := limit.slots["a"]; ok {
+		t.Fatalf("slots for idle key a were not removed")
+	}
+	if len(limit.slots) != 1 {
+		t.Fatalf("keys = %d, want only b", len(limit.slots))
+	}
+
+	releaseB()
+	if len(limit.slots) != 0 {
+		t.Fatalf("keys = %d after all releases, want 0", len(limit.slots))
+	}
+
+	// Ключ снова доступен после удаления
+	release, err := limit.acquire(ctx, "a")
+	if err != nil {
+		t.Fatalf("acquire(a) after prune error = %v", err)
+	}
+	release()
+}
+
+func TestKeyedLimitKeepsSlotsForWaiters(t *testing.T) {
+	limit := &keyedLimit{n: 1, slots: make(map[string]*keyedSlots)}
+	ctx := context.Background()
+
+	release, _ := limit.acquire(ctx, "a")
+	acquired := make(chan func())
+	go func() {
+		next, _ := limit.acquire(ctx, "a")
+		acquired <- next
+	}()
+	for {
+		limit.mu.Lock()
+		refs := limit.slots["a"].refs
+		limit.mu.Unlock()
+		if refs == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Слоты ожидающей попытки не удаляются, иначе третья попытка получила бы новые и обошла лимит
+	release()
+	next := <-acquired
+	if _, err := limit.acquire(ctxWithTimeout(t, 10*time.Millisecond), "a"); err == nil {
+		t.Fatalf("acquire(a) error = nil, want the limit to hold for the waiter's slot")
+	}
+	next()
+	if len(limit.slots) != 0 {
+		t.Fatalf("keys = %d after all releases, want 0", len(limit.slots))
+	}
+}
+
+// ctxWithTimeout возвращает контекст с таймаутом d, отменяемый по окончании теста
+func ctxWithTimeout(t *testing.T, d time.Duration) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	t.Cleanup(cancel)
+	return ctx
+}
@@ -0,0 +1,55 @@
+package retry
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// retrySlots — глобальный семафор фазы повтора (nil = без ограничения)
+var retrySlots atomic.Pointer[chan struct{}]
+
+// retriesDisabled — глобальный выключатель повторов SetRetriesEnabled
+var retriesDisabled atomic.Bool
+
+// SetRetriesEnabled включает или выключает повторы во всём процессе, например чтобы сбросить
+// нагрузку во время инцидента через admin endpoint. При выключенных повторах каждый WithRetry
+// завершается после первой неудачной попытки с AttemptsExhausted; уже идущие циклы
+// не планируют новых повторов. Безопасна для конкурентного вызова.
+func SetRetriesEnabled(enabled bool) {
+	retriesDisabled.Store(!enabled)
+}
+
+// RetriesEnabled сообщает, включены ли повторы глобально (по умолчанию включены)
+func RetriesEnabled() bool {
+	return !retriesDisabled.Load()
+}
+
+// SetMaxConcurrentRetries ограничивает число операций во всём процессе, одновременно
+// находящихся в фазе повтора (ожидание задержки и повторная попытка), чтобы сдерживать
+// нагрузку при массовых сбоях. n <= 0 снимает ограничение (по умолчанию).
+// Безопасна для конкурентного вызова; новое значение действует для повторов, начатых после вызова.
+func SetMaxConcurrentRetries(n int) {
+	if n <= 0 {
+		retrySlots.Store(nil)
+		return
+	}
+	slots := make(chan struct{}, n)
+	retrySlots.Store(&slots)
+}
+
+// acquireRetrySlot занимает слот фазы повтора, ожидая его не дольше жизни контекста,
+// и возвращает функцию освобождения
+func acquireRetrySlot(ctx context.Context) (func(), error) {
+	p := retrySlots.Load()
+	if p == nil {
+		return func() {}, nil
+	}
+
+	slots := *p
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
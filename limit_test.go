@@ -0,0 +1,324 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errTest = errors.New("test error")
+
+// errRetriable — повторяемая ошибка для тестов: HTTP 503
+var errRetriable = &HTTPError{StatusCode: 503, Message: "Service Unavailable"}
+
+func TestMaxConcurrentRetries(t *testing.T) {
+	SetMaxConcurrentRetries(2)
+	t.Cleanup(func() { SetMaxConcurrentRetries(0) })
+
+	var active, peak atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			attempt := 0
+			_, err := WithRetry(context.Background(), RetryConfig{MinDelay: time.Millisecond, MaxDelay: time.Millisecond}, "op",
+				func(context.Context) (int, error) {
+					attempt++
+					if attempt == 1 {
+						return 0, errRetriable
+					}
+					// Повторная попытка выполняется в фазе повтора и занимает слот
+					n := active.Add(1)
+					for {
+						p := peak.Load()
+						if n <= p || peak.CompareAndSwap(p, n) {
+							break
+						}
+					}
+					time.Sleep(5 * time.Millisecond)
+					active.Add(-1)
+					return attempt, nil
+				})
+			if err != nil {
+				t.Errorf("WithRetry() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > 2 {
+		t.Fatalf("concurrent retries = %d, want at most 2", got)
+	}
+}
+
+func TestMaxConcurrentRetriesCanceledWhileWaiting(t *testing.T) {
+	SetMaxConcurrentRetries(1)
+	t.Cleanup(func() { SetMaxConcurrentRetries(0) })
+
+	// Первый вызов занимает единственный слот и ждёт задержку
+	clock := newManualClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = WithRetry(ctx, RetryConfig{Clock: clock}, "holder", func(context.Context) (int, error) {
+			return 0, errRetriable
+		})
+	}()
+	<-clock.waiting
+
+	// Второй вызов ждёт слот, пока не истечёт его контекст
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer waitCancel()
+	_, err := WithRetry(waitCtx, RetryConfig{MinDelay: time.Millisecond}, "waiter", func(context.Context) (int, error) {
+		return 0, errRetriable
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WithRetry() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestMaxConcurrentRetriesReleasedOnExit(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(ctx context.Context, clock *manualClock)
+	}{
+		{
+			name: "canceled during sleep",
+			run: func(ctx context.Context, clock *manualClock) {
+				ctx, cancel := context.WithCancel(ctx)
+				go func() {
+					<-clock.waiting
+					cancel()
+				}()
+				_, _ = WithRetry(ctx, RetryConfig{Clock: clock}, "op", func(context.Context) (int, error) {
+					return 0, errRetriable
+				})
+			},
+		},
+		{
+			name: "gate error",
+			run: func(ctx context.Context, clock *manualClock) {
+				calls := 0
+				config := RetryConfig{Clock: clock, Gate: func(context.Context) error {
+					calls++
+					if calls == 2 {
+						return errTest
+					}
+					return nil
+				}}
+				runAdvancing(ctx, clock, config)
+			},
+		},
+		{
+			name: "before attempt error",
+			run: func(ctx context.Context, clock *manualClock) {
+				config := RetryConfig{Clock: clock, BeforeAttempt: func(ctx context.Context, attempt int) (context.Context, error) {
+					if attempt == 2 {
+						return nil, errTest
+					}
+					return ctx, nil
+				}}
+				runAdvancing(ctx, clock, config)
+			},
+		},
+		{
+			name: "host gate error",
+			run: func(ctx context.Context, clock *manualClock) {
+				calls := 0
+				config := RetryConfig{Clock: clock, HostGate: func(context.Context, string) (func(), error) {
+					calls++
+					if calls == 2 {
+						return nil, errTest
+					}
+					return func() {}, nil
+				}}
+				runAdvancing(ctx, clock, config)
+			},
+		},
+		{
+			name: "stop retry",
+			run: func(ctx context.Context, clock *manualClock) {
+				attempt := 0
+				go func() {
+					<-clock.waiting
+					clock.Advance(time.Hour)
+				}()
+				_, _ = WithRetry(ctx, RetryConfig{Clock: clock}, "op", func(context.Context) (int, error) {
+					attempt++
+					if attempt == 2 {
+						return StopRetry(0, errTest)
+					}
+					return 0, errRetriable
+				})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetMaxConcurrentRetries(1)
+			t.Cleanup(func() { SetMaxConcurrentRetries(0) })
+
+			tt.run(context.Background(), newManualClock())
+
+			// Если слот не освобождён, следующий повтор ждёт его до истечения контекста
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			attempt := 0
+			_, err := WithRetry(ctx, RetryConfig{MinDelay: time.Millisecond}, "next", func(context.Context) (int, error) {
+				attempt++
+				if attempt == 1 {
+					return 0, errRetriable
+				}
+				return attempt, nil
+			})
+			if err != nil {
+				t.Fatalf("retry after exit: error = %v, want nil (leaked retry slot)", err)
+			}
+		})
+	}
+}
+
+// runAdvancing выполняет WithRetry с постоянно неудачной операцией, сдвигая часы
+// при каждом ожидании, чтобы цикл дошёл до выхода, заданного config
+func runAdvancing(ctx context.Context, clock *manualClock, config RetryConfig) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-clock.waiting:
+				clock.Advance(time.Hour)
+			}
+		}
+	}()
+	_, _ = WithRetry(ctx, config, "op", func(context.Context) (int, error) {
+		return 0, errRetriable
+	})
+}
+
+func TestSetRetriesEnabled(t *testing.T) {
+	t.Cleanup(func() { SetRetriesEnabled(true) })
+
+	SetRetriesEnabled(false)
+	if RetriesEnabled() {
+		t.Fatal("RetriesEnabled() = true after SetRetriesEnabled(false)")
+	}
+	op, calls := failing(errRetriable)
+	_, err := WithRetry(context.Background(), RetryConfig{Clock: newFakeClock(), MaxAttempts: 5}, "op", op)
+	requireReason(t, err, AttemptsExhausted)
+	if *calls != 1 {
+		t.Fatalf("calls = %d with retries disabled, want a single attempt", *calls)
+	}
+
+	SetRetriesEnabled(true)
+	op, calls = failing(errRetriable)
+	_, _ = WithRetry(context.Background(), RetryConfig{Clock: newFakeClock(), MaxAttempts: 5}, "op", op)
+	if *calls != 5 {
+		t.Fatalf("calls = %d after re-enabling, want 5", *calls)
+	}
+}
+
+func TestSetRetriesEnabledMidRun(t *testing.T) {
+	// Выключение во время ожидания: запланированная попытка выполняется, новых повторов нет
+	t.Cleanup(func() { SetRetriesEnabled(true) })
+	clock := newManualClock()
+	go func() {
+		<-clock.waiting
+		SetRetriesEnabled(false)
+		clock.Advance(time.Hour)
+	}()
+
+	op, calls := failing(errRetriable)
+	_, err := WithRetry(context.Background(), RetryConfig{Clock: clock, MaxAttempts: 10}, "op", op)
+	requireReason(t, err, AttemptsExhausted)
+	if *calls != 2 {
+		t.Fatalf("calls = %d, want the attempt in flight and no more", *calls)
+	}
+}
+
+func TestSetRetriesEnabledConcurrent(t *testing.T) {
+	// Запускать с -race: переключение из admin endpoint идёт параллельно с повторами
+	t.Cleanup(func() { SetRetriesEnabled(true) })
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				SetRetriesEnabled((i+j)%2 == 0)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				op, calls := failing(errRetriable)
+				_, _ = WithRetry(context.Background(), RetryConfig{Clock: newFakeClock(), MaxAttempts: 3}, "op", op)
+				if *calls < 1 || *calls > 3 {
+					t.Errorf("calls = %d, want between 1 and 3", *calls)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestBudgetSharedAcrossConcurrentRetries(t *testing.T) {
+	// Пять токенов на десять операций: после их расхода все операции прекращают повторы
+	const capacity, operations = 5, 10
+	clock := newFakeClock()
+	budget := NewBudget(capacity, 1)
+	config := RetryConfig{
+		Clock:       clock,
+		MaxAttempts: 100,
+		MinDelay:    time.Millisecond,
+		MaxDelay:    time.Millisecond,
+		Jitter:      noJitter,
+		Budget:      budget,
+	}
+
+	var retries atomic.Int32
+	var wg sync.WaitGroup
+	for range operations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := WithRetry(context.Background(), config, "op", func(context.Context) (int, error) {
+				return 0, errRetriable
+			})
+			var retryErr *RetryError
+			if !errors.As(err, &retryErr) || retryErr.Reason != BudgetExhausted {
+				t.Errorf("WithRetry() error = %v, want RetryError with %s", err, BudgetExhausted)
+				return
+			}
+			retries.Add(int32(retryErr.Attempts - 1))
+		}()
+	}
+	wg.Wait()
+
+	// Ожидания по 1ms пополняют бюджет на тысячные доли токена — лишнего повтора не даёт
+	if got := retries.Load(); got != capacity {
+		t.Fatalf("retries across operations = %d, want %d", got, capacity)
+	}
+
+	// Бюджет пополняется по Clock: через 3s доступно ещё три повтора
+	clock.Advance(3 * time.Second)
+	op, calls := failing(errRetriable)
+	_, err := WithRetry(context.Background(), config, "op", op)
+	requireReason(t, err, BudgetExhausted)
+	if *calls != 4 {
+		t.Fatalf("calls after refill = %d, want 4", *calls)
+	}
+}
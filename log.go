@@ -0,0 +1,60 @@
+package retry
+
+import (
+	"context"
+	"log/slog"
+)
+
+// logAttrs пишет запись в Logger конфигурации, если он задан и включён для уровня level.
+// LogFields вычисляются только тогда, когда запись действительно будет записана.
+// При GroupLogs атрибуты retry вкладываются в группу "retry", LogFields остаются на верхнем уровне.
+func logAttrs(ctx context.Context, config RetryConfig, level slog.Level, msg string, attrs ...slog.Attr) {
+	if config.Logger == nil || !config.Logger.Enabled(ctx, level) {
+		return
+	}
+	if config.LogErrorDepth {
+		for _, attr := range attrs {
+			if err, ok := attr.Value.Any().(error); ok && attr.Key == "error" {
+				attrs = append(attrs, slog.Int("error_depth", errorDepth(err)))
+				break
+			}
+		}
+	}
+	if config.GroupLogs {
+		attrs = []slog.Attr{{Key: "retry", Value: slog.GroupValue(attrs...)}}
+	}
+	if config.LogFields != nil {
+		attrs = append(attrs, config.LogFields()...)
+	}
+	config.Logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// withLogAttrs возвращает копию конфигурации, в логи которой после LogFields добавляются attrs
+func withLogAttrs(config RetryConfig, attrs ...slog.Attr) RetryConfig {
+	fields := config.LogFields
+	config.LogFields = func() []slog.Attr {
+		if fields == nil {
+			return attrs
+		}
+		return append(fields(), attrs...)
+	}
+	return config
+}
+
+// errorDepth возвращает длину цепочки обёрток ошибки: 1 для необёрнутой ошибки,
+// для объединённой (errors.Join) — по самой длинной ветви
+func errorDepth(err error) int {
+	switch e := err.(type) {
+	case nil:
+		return 0
+	case interface{ Unwrap() []error }:
+		depth := 0
+		for _, child := range e.Unwrap() {
+			depth = max(depth, errorDepth(child))
+		}
+		return depth + 1
+	case interface{ Unwrap() error }:
+		return errorDepth(e.Unwrap()) + 1
+	}
+	return 1
+}
@@ -0,0 +1,269 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordHandler — обработчик slog, сохраняющий записи для проверки их структуры
+type recordHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordHandler) Handle(_ context.Context, record slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, record.Clone())
+	return nil
+}
+
+func (h *recordHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *recordHandler) WithGroup(string) slog.Handler      { return h }
+
+// recordAttrs возвращает атрибуты верхнего уровня записи по ключам
+func recordAttrs(record slog.Record) map[string]slog.Value {
+	attrs := make(map[string]slog.Value)
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs[attr.Key] = attr.Value
+		return true
+	})
+	return attrs
+}
+
+// retryLogRecord выполняет одну неудачную попытку с повтором и возвращает запись о неудаче
+func retryLogRecord(t *testing.T, groupLogs bool) slog.Record {
+	t.Helper()
+	handler := &recordHandler{}
+	_, _ = WithRetry(context.Background(), RetryConfig{
+		Clock:       newFakeClock(),
+		MaxAttempts: 2,
+		Logger:      slog.New(handler),
+		LogFields:   func() []slog.Attr { return []slog.Attr{slog.String("request_id", "42")} },
+		GroupLogs:   groupLogs,
+	}, "fetch", failTimes(1, errRetriable))
+
+	for _, record := range handler.records {
+		if record.Message == "Operation failed, will retry" {
+			return record
+		}
+	}
+	t.Fatalf("no retry record among %d records", len(handler.records))
+	return slog.Record{}
+}
+
+func TestGroupLogs(t *testing.T) {
+	attrs := recordAttrs(retryLogRecord(t, true))
+
+	// Атрибуты retry вложены в группу, LogFields остаются на верхнем уровне
+	if len(attrs) != 2 || attrs["request_id"].String() != "42" {
+		t.Fatalf("top-level attrs = %v, want only the retry group and request_id", attrs)
+	}
+	group, ok := attrs["retry"]
+	if !ok || group.Kind() != slog.KindGroup {
+		t.Fatalf("retry attr = %v, want a group", group)
+	}
+	grouped := make(map[string]slog.Value)
+	for _, attr := range group.Group() {
+		grouped[attr.Key] = attr.Value
+	}
+	if grouped["operation"].String() != "fetch" || grouped["attempt"].Int64() != 1 {
+		t.Fatalf("retry group = %v, want operation and attempt inside", grouped)
+	}
+	if _, ok := grouped["error"]; !ok {
+		t.Fatalf("retry group = %v, want the error inside", grouped)
+	}
+	if _, ok := grouped["request_id"]; ok {
+		t.Fatalf("retry group = %v, want LogFields outside the group", grouped)
+	}
+}
+
+func TestFlatLogsByDefault(t *testing.T) {
+	attrs := recordAttrs(retryLogRecord(t, false))
+	if _, ok := attrs["retry"]; ok {
+		t.Fatalf("attrs = %v, want no retry group without GroupLogs", attrs)
+	}
+	if attrs["operation"].String() != "fetch" || attrs["request_id"].String() != "42" {
+		t.Fatalf("attrs = %v, want operation and request_id at the top level", attrs)
+	}
+}
+
+// wrapError — обёртка с собственным Unwrap
+type wrapError struct{ err error }
+
+func (e wrapError) Error() string { return "wrap: " + e.err.Error() }
+func (e wrapError) Unwrap() error { return e.err }
+
+func TestErrorDepth(t *testing.T) {
+	base := errors.New("base")
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, 0},
+		{"plain", base, 1},
+		{"fmt wrapped twice", fmt.Errorf("b: %w", fmt.Errorf("a: %w", base)), 3},
+		{"custom wrapper", wrapError{fmt.Errorf("a: %w", base)}, 3},
+		{"joined takes the longest branch", errors.Join(base, fmt.Errorf("a: %w", wrapError{base})), 4},
+	}
+	for _, tt := range tests {
+		if got := errorDepth(tt.err); got != tt.want {
+			t.Fatalf("%s: errorDepth() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLogErrorDepth(t *testing.T) {
+	// Цепочка глубины 4: три обёртки над HTTPError
+	chain := fmt.Errorf("sync: %w", wrapError{fmt.Errorf("fetch: %w", errRetriable)})
+	for _, enabled := range []bool{true, false} {
+		handler := &recordHandler{}
+		_, _ = WithRetry(context.Background(), RetryConfig{
+			Clock:         newFakeClock(),
+			MaxAttempts:   2,
+			Logger:        slog.New(handler),
+			LogErrorDepth: enabled,
+		}, "op", func(context.Context) (int, error) { return 0, chain })
+
+		failures := 0
+		for _, record := range handler.records {
+			attrs := recordAttrs(record)
+			if _, ok := attrs["error"]; !ok {
+				continue
+			}
+			failures++
+			depth, ok := attrs["error_depth"]
+			if enabled && (!ok || depth.Int64() != 4) {
+				t.Fatalf("%q: error_depth = %v, want 4", record.Message, depth)
+			}
+			if !enabled && ok {
+				t.Fatalf("%q: error_depth logged without LogErrorDepth", record.Message)
+			}
+		}
+		if failures == 0 {
+			t.Fatal("no failure records logged")
+		}
+	}
+}
+
+func TestRetryLogScheduleAttrs(t *testing.T) {
+	handler := &recordHandler{}
+	op, _ := failing(errRetriable)
+	_, _ = WithRetry(context.Background(), RetryConfig{
+		Clock:       newFakeClock(),
+		MaxAttempts: 3,
+		MinDelay:    time.Second,
+		MaxDelay:    time.Second,
+		Jitter:      noJitter,
+		Logger:      slog.New(handler),
+	}, "op", op)
+
+	var failures []map[string]slog.Value
+	for _, record := range handler.records {
+		if record.Level == slog.LevelError {
+			failures = append(failures, recordAttrs(record))
+		}
+	}
+	if len(failures) != 3 {
+		t.Fatalf("%d failure records, want one per attempt", len(failures))
+	}
+	// Запланированный повтор указывает задержку и номер следующей попытки
+	for i, attrs := range failures[:2] {
+		if attrs["next_attempt"].Int64() != int64(i+2) || attrs["delay"].Duration() != time.Second {
+			t.Fatalf("attempt %d attrs = %v, want next_attempt %d and delay 1s", i+1, attrs, i+2)
+		}
+	}
+	// После последней попытки повтора нет — и атрибутов расписания тоже
+	last := failures[2]
+	if _, ok := last["next_attempt"]; ok {
+		t.Fatalf("final attempt attrs = %v, want no next_attempt", last)
+	}
+	if _, ok := last["delay"]; ok {
+		t.Fatalf("final attempt attrs = %v, want no delay", last)
+	}
+}
+
+func TestLogFieldsLazy(t *testing.T) {
+	tests := []struct {
+		name   string
+		logger *slog.Logger
+	}{
+		{"nil logger", nil},
+		{"level disabled", slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			_, err := WithRetry(context.Background(), RetryConfig{
+				Clock:       newFakeClock(),
+				MaxAttempts: 3,
+				Logger:      tt.logger,
+				LogFields: func() []slog.Attr {
+					calls++
+					return nil
+				},
+			}, "op", failTimes(5, errRetriable))
+			if err == nil {
+				t.Fatalf("WithRetry() error = nil, want exhausted attempts")
+			}
+			if calls != 0 {
+				t.Fatalf("LogFields called %d times, want none without an enabled logger", calls)
+			}
+		})
+	}
+
+	// С включённым логгером LogFields вычисляются ровно один раз на каждую запись
+	handler := &recordHandler{}
+	calls := 0
+	_, _ = WithRetry(context.Background(), RetryConfig{
+		Clock:       newFakeClock(),
+		MaxAttempts: 3,
+		Logger:      slog.New(handler),
+		LogFields: func() []slog.Attr {
+			calls++
+			return nil
+		},
+	}, "op", failTimes(5, errRetriable))
+	if calls == 0 || calls != len(handler.records) {
+		t.Fatalf("LogFields called %d times for %d records, want once per record", calls, len(handler.records))
+	}
+}
+
+func TestDisableSuccessLog(t *testing.T) {
+	messages := func(disable bool) []string {
+		handler := &recordHandler{}
+		_, err := WithRetry(context.Background(), RetryConfig{
+			Clock:             newFakeClock(),
+			MaxAttempts:       3,
+			Logger:            slog.New(handler),
+			DisableSuccessLog: disable,
+		}, "op", failTimes(1, errRetriable))
+		if err != nil {
+			t.Fatalf("WithRetry() error = %v", err)
+		}
+		var out []string
+		for _, record := range handler.records {
+			out = append(out, record.Message)
+		}
+		return out
+	}
+
+	const failed, succeeded = "Operation failed, will retry", "Operation succeeded after retry"
+	if got := messages(false); !slices.Equal(got, []string{failed, succeeded}) {
+		t.Fatalf("messages = %q, want %q", got, []string{failed, succeeded})
+	}
+	// Пропадает только запись об успехе, запись о неудаче остаётся
+	if got := messages(true); !slices.Equal(got, []string{failed}) {
+		t.Fatalf("messages with DisableSuccessLog = %q, want %q", got, []string{failed})
+	}
+}
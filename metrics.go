@@ -0,0 +1,12 @@
+package retry
+
+import "time"
+
+// Metrics получает события цикла повторных попыток для сбора метрик.
+// Реализации должны быть безопасны для конкурентного использования.
+type Metrics interface {
+	Attempt(operation string, duration time.Duration, err error) // Завершена попытка
+	Retry(operation string, attempt int)                         // Запланирован повтор после попытки attempt
+	Success(operation string, attempts int)                      // Операция успешна за attempts попыток
+	GiveUp(operation string, attempts int, reason Reason)        // Попытки прекращены с RetryError
+}
@@ -0,0 +1,56 @@
+package retry
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Pacer — общая ограниченная очередь повторов для нескольких операций. Повторы выполняются
+// не чаще одного за interval: если после всплеска отказов таймеры сработали бы разом,
+// Pacer раздвигает их по времени, сдвигая каждый повтор на ближайшее свободное окно.
+// Цена — дополнительная задержка: в очереди не больше capacity повторов, поэтому
+// ожидание сверх backoff не превышает capacity*interval. При заполненной очереди
+// попытки прекращаются с Reason = QueueFull.
+type Pacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	capacity int
+	slots    []time.Time // Запланированные моменты повторов по возрастанию
+}
+
+// NewPacer создаёт очередь, пропускающую один повтор за interval и вмещающую capacity повторов
+func NewPacer(interval time.Duration, capacity int) *Pacer {
+	return &Pacer{
+		interval: interval,
+		capacity: max(capacity, 1),
+	}
+}
+
+// reserve занимает ближайшее свободное окно не раньше ready.
+// false, если очередь заполнена.
+func (p *Pacer) reserve(now, ready time.Time) (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Прошедшие окна больше не мешают новым повторам
+	p.slots = slices.DeleteFunc(p.slots, func(slot time.Time) bool {
+		return slot.Add(p.interval).Before(now)
+	})
+	if len(p.slots) >= p.capacity {
+		return ready, false
+	}
+
+	slot, i := ready, 0
+	for ; i < len(p.slots); i++ {
+		if !p.slots[i].Add(p.interval).After(slot) {
+			continue
+		}
+		if !slot.Add(p.interval).After(p.slots[i]) {
+			break
+		}
+		slot = p.slots[i].Add(p.interval)
+	}
+	p.slots = slices.Insert(p.slots, i, slot)
+	return slot, true
+}
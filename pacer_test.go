@@ -0,0 +1,94 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// pacedBurst запускает burst операций с общим Pacer, каждая падает один раз и затем успешна.
+// Ждёт, пока paced вызовов встанут на ожидание, а остальные завершатся, и только затем
+// сдвигает часы: все отказы всплеска приходятся на один момент. Возвращает задержки
+// повторов по возрастанию и ошибки вызовов.
+func pacedBurst(t *testing.T, burst, paced int, pacer *Pacer) ([]time.Duration, []error) {
+	t.Helper()
+	clock := newManualClock()
+	config := RetryConfig{
+		Clock:       clock,
+		MaxAttempts: 2,
+		MinDelay:    time.Second,
+		MaxDelay:    time.Second,
+		Jitter:      noJitter,
+		Pacer:       pacer,
+	}
+
+	results := make(chan error, burst)
+	for range burst {
+		go func() {
+			_, err := WithRetry(context.Background(), config, "op", failTimes(1, errRetriable))
+			results <- err
+		}()
+	}
+
+	var delays []time.Duration
+	var errs []error
+	timeout := time.After(5 * time.Second)
+	for len(delays) < paced || len(errs) < burst-paced {
+		select {
+		case delay := <-clock.waiting:
+			delays = append(delays, delay)
+		case err := <-results:
+			errs = append(errs, err)
+		case <-timeout:
+			t.Fatalf("burst stalled: %d waits and %d results, want %d and %d", len(delays), len(errs), paced, burst-paced)
+		}
+	}
+	clock.Advance(time.Hour)
+	for len(errs) < burst {
+		errs = append(errs, <-results)
+	}
+	slices.Sort(delays)
+	return delays, errs
+}
+
+func TestPacerSpreadsBurst(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	delays, errs := pacedBurst(t, 8, 8, NewPacer(interval, 16))
+
+	// Без Pacer все повторы сработали бы через 1s; с ним они идут с шагом interval
+	var want []time.Duration
+	for i := range 8 {
+		want = append(want, time.Second+time.Duration(i)*interval)
+	}
+	if !slices.Equal(delays, want) {
+		t.Fatalf("delays = %v, want %v", delays, want)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("call %d: error = %v, want success after the paced retry", i, err)
+		}
+	}
+}
+
+func TestPacerQueueFull(t *testing.T) {
+	// Очередь на 3 повтора: остальные вызовы всплеска прекращаются с QueueFull
+	delays, errs := pacedBurst(t, 8, 3, NewPacer(100*time.Millisecond, 3))
+	if len(delays) != 3 || delays[2]-delays[0] != 200*time.Millisecond {
+		t.Fatalf("delays = %v, want 3 retries spaced by the interval", delays)
+	}
+
+	full := 0
+	for _, err := range errs {
+		var retryErr *RetryError
+		if errors.As(err, &retryErr) && retryErr.Reason == QueueFull {
+			full++
+		} else if err != nil {
+			t.Fatalf("error = %v, want success or %s", err, QueueFull)
+		}
+	}
+	if full != 5 {
+		t.Fatalf("%d calls ended with %s, want 5", full, QueueFull)
+	}
+}
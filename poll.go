@@ -0,0 +1,67 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrConditionNotMet — результат Poll так и не удовлетворил условию, хотя операция
+// выполнялась успешно. Внутри цикла считается повторяемой ошибкой попытки.
+var ErrConditionNotMet = errors.New("retry: poll condition not met")
+
+// Poll повторяет операцию, пока done(result) не вернёт true, либо пока не исчерпаны
+// попытки, бюджет времени или контекст. Успешный результат, не удовлетворяющий условию,
+// считается повторяемой неудачей. Если условие так и не выполнено, возвращается последний
+// полученный результат и ошибка ErrConditionNotMet с его описанием; если неудачной была
+// сама операция — RetryError.
+func Poll[T any](
+	ctx context.Context,
+	config RetryConfig,
+	operationName string,
+	operationFn func(context.Context) (T, error),
+	done func(T) bool,
+) (T, error) {
+	if operationFn == nil || done == nil {
+		var zero T
+		return zero, ErrNilOperation
+	}
+
+	var last T
+	result, err := WithRetry(ctx, config, operationName, func(ctx context.Context) (T, error) {
+		value, err := operationFn(ctx)
+		if err != nil {
+			return value, err
+		}
+		last = value
+		if !done(value) {
+			return value, ErrConditionNotMet
+		}
+		return value, nil
+	})
+	if err != nil && errors.Is(err, ErrConditionNotMet) {
+		// Отдельная ошибка вместо RetryError: операция не падала, но нужное состояние не достигнуто
+		var retryErr *RetryError
+		if errors.As(err, &retryErr) {
+			return last, fmt.Errorf("%w after %d attempts, last result: %+v", ErrConditionNotMet, retryErr.Attempts, last)
+		}
+		return last, err
+	}
+	return result, err
+}
+
+// RetryWhileZero возвращает условие done для Poll: опрос продолжается, пока результат
+// равен нулевому значению типа (например, 0 или "")
+func RetryWhileZero[T comparable]() func(T) bool {
+	return func(value T) bool {
+		var zero T
+		return value != zero
+	}
+}
+
+// RetryWhileFalse возвращает условие done для Poll: опрос продолжается, пока результат false
+func RetryWhileFalse() func(bool) bool {
+	return func(value bool) bool {
+		return value
+	}
+}
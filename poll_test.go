@@ -0,0 +1,148 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// states возвращает операцию, отдающую значения values по очереди (последнее повторяется)
+func states(values ...string) (func(context.Context) (string, error), *int) {
+	calls := 0
+	return func(context.Context) (string, error) {
+		calls++
+		return values[min(calls, len(values))-1], nil
+	}, &calls
+}
+
+func TestPollDoneOnThirdPoll(t *testing.T) {
+	op, calls := states("pending", "pending", "ready")
+	result, err := Poll(context.Background(), RetryConfig{Clock: newFakeClock(), MaxAttempts: 5}, "op", op,
+		func(state string) bool { return state == "ready" })
+	if err != nil || result != "ready" || *calls != 3 {
+		t.Fatalf("Poll() = %q, %v after %d polls, want ready after 3", result, err, *calls)
+	}
+}
+
+func TestPollConditionNotMet(t *testing.T) {
+	op, calls := states("pending", "provisioning")
+	result, err := Poll(context.Background(), RetryConfig{Clock: newFakeClock(), MaxAttempts: 3}, "op", op,
+		func(state string) bool { return state == "ready" })
+	if !errors.Is(err, ErrConditionNotMet) {
+		t.Fatalf("Poll() error = %v, want ErrConditionNotMet", err)
+	}
+	var retryErr *RetryError
+	if errors.As(err, &retryErr) {
+		t.Fatalf("Poll() error = %v, want a condition error rather than RetryError", err)
+	}
+	if result != "provisioning" || *calls != 3 {
+		t.Fatalf("Poll() result = %q after %d polls, want the last result after 3", result, *calls)
+	}
+}
+
+func TestPollOperationFailure(t *testing.T) {
+	// Неудача самой операции отличается от недостигнутого состояния
+	_, err := Poll(context.Background(), RetryConfig{Clock: newFakeClock(), MaxAttempts: 2}, "op",
+		func(context.Context) (string, error) { return "", errRetriable },
+		func(string) bool { return true })
+	requireReason(t, err, AttemptsExhausted)
+	if errors.Is(err, ErrConditionNotMet) {
+		t.Fatalf("Poll() error = %v, want no ErrConditionNotMet", err)
+	}
+}
+
+func TestRetryWhileZero(t *testing.T) {
+	done := RetryWhileZero[int]()
+	if done(0) || !done(1) || !done(-1) {
+		t.Fatalf("RetryWhileZero[int](): want done only for a non-zero value")
+	}
+
+	op, calls := states("", "", "job-42")
+	result, err := Poll(context.Background(), RetryConfig{Clock: newFakeClock(), MaxAttempts: 5}, "op", op,
+		RetryWhileZero[string]())
+	if err != nil || result != "job-42" || *calls != 3 {
+		t.Fatalf("Poll() = %q, %v after %d polls, want job-42 after 3", result, err, *calls)
+	}
+}
+
+func TestRetryWhileFalse(t *testing.T) {
+	done := RetryWhileFalse()
+	if done(false) || !done(true) {
+		t.Fatalf("RetryWhileFalse(): want done only for true")
+	}
+
+	calls := 0
+	ready, err := Poll(context.Background(), RetryConfig{Clock: newFakeClock(), MaxAttempts: 5}, "op",
+		func(context.Context) (bool, error) {
+			calls++
+			return calls == 4, nil
+		}, RetryWhileFalse())
+	if err != nil || !ready || calls != 4 {
+		t.Fatalf("Poll() = %t, %v after %d polls, want true after 4", ready, err, calls)
+	}
+
+	// Результат так и не стал true — опрос заканчивается ErrConditionNotMet
+	_, err = Poll(context.Background(), RetryConfig{Clock: newFakeClock(), MaxAttempts: 2}, "op",
+		func(context.Context) (bool, error) { return false, nil }, RetryWhileFalse())
+	if !errors.Is(err, ErrConditionNotMet) {
+		t.Fatalf("Poll() error = %v, want ErrConditionNotMet", err)
+	}
+}
+
+func TestPollFailureModes(t *testing.T) {
+	// Сценарий попыток: "" — ошибка операции, иначе состояние
+	scripted := func(outcomes ...string) func(context.Context) (string, error) {
+		calls := 0
+		return func(context.Context) (string, error) {
+			outcome := outcomes[min(calls, len(outcomes)-1)]
+			calls++
+			if outcome == "" {
+				return "", errRetriable
+			}
+			return outcome, nil
+		}
+	}
+
+	tests := []struct {
+		name         string
+		config       RetryConfig
+		outcomes     []string
+		notMet       bool
+		wantInErrMsg string
+	}{
+		{"never satisfied", RetryConfig{MaxAttempts: 3}, []string{"pending"}, true, "after 3 attempts, last result: pending"},
+		{"errors, then not satisfied", RetryConfig{MaxAttempts: 3}, []string{"", "", "pending"}, true, "last result: pending"},
+		{"time budget, never satisfied", RetryConfig{MaxAttempts: 100, MinDelay: time.Second, MaxDelay: time.Second, MaxElapsedTime: 5 * time.Second},
+			[]string{"pending"}, true, "last result: pending"},
+		{"last attempt failed", RetryConfig{MaxAttempts: 3}, []string{"pending", "pending", ""}, false, ""},
+		{"operation kept failing", RetryConfig{MaxAttempts: 3}, []string{""}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.Clock = newFakeClock()
+			result, err := Poll(context.Background(), config, "op", scripted(tt.outcomes...),
+				func(state string) bool { return state == "ready" })
+
+			var retryErr *RetryError
+			isRetryErr := errors.As(err, &retryErr)
+			if tt.notMet {
+				if !errors.Is(err, ErrConditionNotMet) || isRetryErr {
+					t.Fatalf("Poll() error = %v, want ErrConditionNotMet without RetryError", err)
+				}
+				if !strings.Contains(err.Error(), tt.wantInErrMsg) || result != "pending" {
+					t.Fatalf("Poll() = %q, %q, want the last result pending described as %q", result, err, tt.wantInErrMsg)
+				}
+				return
+			}
+			if !isRetryErr || errors.Is(err, ErrConditionNotMet) {
+				t.Fatalf("Poll() error = %v, want RetryError for the failing operation", err)
+			}
+			if !errors.Is(err, errRetriable) {
+				t.Fatalf("Poll() error = %v, want the operation error inside", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package retry
+
+import (
+	"context"
+	"sync"
+)
+
+// registry хранит конфигурации операций, зарегистрированные через Register
+var registry = struct {
+	sync.RWMutex
+	configs map[string]RetryConfig
+}{configs: make(map[string]RetryConfig)}
+
+// Register задаёт конфигурацию по умолчанию для операции name, заменяя прежнюю.
+// Обычно вызывается при старте; чтение из реестра безопасно из нескольких горутин.
+func Register(name string, config RetryConfig) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.configs[name] = config
+}
+
+// Registered возвращает конфигурацию, зарегистрированную для операции name
+func Registered(name string) (RetryConfig, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	config, ok := registry.configs[name]
+	return config, ok
+}
+
+// WithRegistered выполняет WithRetry с конфигурацией, зарегистрированной для operationName.
+// Для незарегистрированной операции используются значения по умолчанию.
+func WithRegistered[T any](
+	ctx context.Context,
+	operationName string,
+	operationFn func(context.Context) (T, error),
+) (T, error) {
+	config, _ := Registered(operationName)
+	return WithRetry(ctx, config, operationName, operationFn)
+}
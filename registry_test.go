@@ -0,0 +1,93 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// register регистрирует конфигурацию на время теста и удаляет её после
+func register(t *testing.T, name string, config RetryConfig) {
+	t.Helper()
+	Register(name, config)
+	t.Cleanup(func() {
+		registry.Lock()
+		defer registry.Unlock()
+		delete(registry.configs, name)
+	})
+}
+
+func TestWithRegisteredUsesRegisteredConfig(t *testing.T) {
+	clock := newFakeClock()
+	register(t, "registry-test", RetryConfig{
+		Clock:       clock,
+		MaxAttempts: 3,
+		MinDelay:    time.Second,
+		MaxDelay:    time.Second,
+		Jitter:      noJitter,
+	})
+
+	op, calls := failing(errRetriable)
+	_, err := WithRegistered(context.Background(), "registry-test", op)
+
+	retryErr := requireReason(t, err, AttemptsExhausted)
+	if *calls != 3 || retryErr.Attempts != 3 {
+		t.Fatalf("calls = %d, Attempts = %d, want 3 from the registered config", *calls, retryErr.Attempts)
+	}
+	if sleeps := clock.Sleeps(); len(sleeps) != 2 || sleeps[0] != time.Second {
+		t.Fatalf("sleeps = %v, want two 1s delays on the registered clock", sleeps)
+	}
+
+	// Повторная регистрация заменяет конфигурацию
+	register(t, "registry-test", RetryConfig{Clock: clock, MaxAttempts: 1})
+	op, calls = failing(errRetriable)
+	if _, err := WithRegistered(context.Background(), "registry-test", op); err == nil || *calls != 1 {
+		t.Fatalf("after re-Register calls = %d, error = %v, want a single failed attempt", *calls, err)
+	}
+}
+
+func TestWithRegisteredUnregisteredUsesDefaults(t *testing.T) {
+	if _, ok := Registered("registry-test-missing"); ok {
+		t.Fatal("Registered() reported an operation that was never registered")
+	}
+
+	result, err := WithRegistered(context.Background(), "registry-test-missing", func(context.Context) (string, error) {
+		return "ok", nil
+	})
+	if err != nil || result != "ok" {
+		t.Fatalf("WithRegistered() = %q, %v, want ok", result, err)
+	}
+
+	// Без конфигурации действуют значения по умолчанию: неповторяемая ошибка не повторяется
+	op, calls := failing(errTest)
+	if _, err := WithRegistered(context.Background(), "registry-test-missing", op); !errors.Is(err, errTest) || *calls != 1 {
+		t.Fatalf("calls = %d, error = %v, want a single attempt returning errTest", *calls, err)
+	}
+}
+
+func TestRegistryConcurrentReads(t *testing.T) {
+	// Запускать с -race: чтения из реестра идут параллельно с регистрацией других операций
+	register(t, "registry-test-shared", RetryConfig{MaxAttempts: 4})
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				if config, ok := Registered("registry-test-shared"); !ok || config.MaxAttempts != 4 {
+					t.Errorf("Registered() = %+v, %t, want MaxAttempts 4", config, ok)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			register(t, fmt.Sprintf("registry-test-writer-%d", i), RetryConfig{MaxAttempts: i + 1})
+		}()
+	}
+	wg.Wait()
+}
@@ -2,14 +2,17 @@ package retry
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/url"
+	"slices"
 	"time"
-
-	"github.com/alfzs/backoff"
 )
 
 // Default значения для повторных попыток
@@ -21,29 +24,288 @@ const (
 
 // RetryConfig содержит параметры для повторных попыток
 type RetryConfig struct {
-	MaxAttempts int              // Максимальное количество попыток
-	MinDelay    time.Duration    // Минимальная задержка
-	MaxDelay    time.Duration    // Максимальная задержка
-	Logger      *slog.Logger     // Логгер (nil = логирование отключено)
-	ShouldRetry func(error) bool // Определяет, стоит ли повторять
+	MaxAttempts int                // Максимальное количество попыток
+	MinDelay    time.Duration      // Минимальная задержка
+	MaxDelay    time.Duration      // Максимальная задержка
+	Logger      *slog.Logger       // Логгер (nil = логирование отключено)
+	LogFields   func() []slog.Attr // Дополнительные поля логов, вычисляются лениво только при записи
+	ShouldRetry func(error) bool   // Определяет, стоит ли повторять
+	Clock       Clock              // Источник времени (nil = системное время)
+	Metrics     Metrics            // Сбор метрик (nil = метрики отключены)
+	Budget      *Budget            // Общий бюджет повторов (nil = без ограничения)
+
+	// Pacer раздвигает повторы разных операций во времени, чтобы после всплеска отказов
+	// они не выполнялись разом (nil = без выравнивания). См. NewPacer.
+	Pacer *Pacer
+
+	// Events получает события каждой попытки, ожидания и завершения. Отправка не блокирует:
+	// при заполненном канале событие отбрасывается, поэтому канал стоит буферизовать.
+	Events chan<- Event
+
+	// DebugWriter получает каждое событие одной читаемой строкой — облегчённая замена Logger
+	// для локальной отладки. Работает независимо от Logger и Events.
+	DebugWriter io.Writer
+
+	// DisableSuccessLog отключает запись "Operation succeeded after retry", сохраняя логи ошибок
+	DisableSuccessLog bool
+	// GroupLogs вкладывает атрибуты логов retry в группу "retry" (retry.attempt, retry.error, ...),
+	// чтобы они не пересекались с полями самой операции; по умолчанию атрибуты плоские
+	GroupLogs bool
+	// LogErrorDepth добавляет к логам с ошибкой атрибут error_depth — длину цепочки обёрток,
+	// помогающую заметить избыточное оборачивание
+	LogErrorDepth bool
+
+	// MaxElapsedTime ограничивает общее время выполнения с учётом попыток и ожиданий
+	// (0 = без ограничения). Каждая задержка, включая jitter, урезается до остатка бюджета,
+	// поэтому суммарное ожидание не превышает MaxElapsedTime.
+	MaxElapsedTime time.Duration
+
+	// OperationTimeout ограничивает всю операцию, включая попытки и ожидания: WithRetry выполняет
+	// цикл в производном контексте с этим таймаутом. По истечении возвращается RetryError
+	// с Reason = TimeBudgetExceeded, для которого errors.Is(err, context.DeadlineExceeded).
+	OperationTimeout time.Duration
+
+	// Deadline — абсолютный момент, после которого попытки не планируются (нулевое значение = не задан).
+	// Действует ближайшая из границ: Deadline, дедлайн контекста и MaxElapsedTime. Если Deadline
+	// уже наступил, операция не запускается: возвращается RetryError с TimeBudgetExceeded.
+	Deadline time.Time
+
+	// MinRemainingBudget — минимальный остаток бюджета времени (MaxElapsedTime, Deadline или дедлайна
+	// контекста) для следующей попытки; при меньшем остатке попытки прекращаются с
+	// TimeBudgetExceeded. По умолчанию MinDelay, отрицательное значение отключает запас.
+	MinRemainingBudget time.Duration
+
+	// RetryIfSlowerThan повторяет успешную попытку, длившуюся дольше порога (признак деградации
+	// бэкенда), пока остаются попытки. Если все попытки медленные или повтор прерван (отмена
+	// контекста, Gate, бюджет), возвращается последний медленный результат без ошибки.
+	RetryIfSlowerThan time.Duration
+
+	// RequireBound требует явного ограничения: MaxAttempts (в том числе из FixedDelays или
+	// WithMaxAttempts), MaxElapsedTime, OperationTimeout, Deadline или дедлайна контекста.
+	// Без него WithRetry сразу возвращает ErrUnbounded, не выполняя операцию. С CountAttempt
+	// лимит попыток не считается ограничением: требуется граница по времени.
+	RequireBound bool
+
+	// StartAttempt задаёт номер первой попытки для возобновлённых операций (по умолчанию 1).
+	// От него считаются backoff и MaxAttempts; хотя бы одна попытка выполняется всегда.
+	StartAttempt int
+
+	// Gate вызывается перед каждой попыткой и может блокироваться (например, на время
+	// обслуживания), пока не вернёт nil. Ошибка Gate прерывает цикл и возвращается как есть.
+	// Gate должен завершаться при отмене переданного контекста.
+	Gate func(ctx context.Context) error
+
+	// HostGate ограничивает попытки по ключу (например, хосту): вызывается перед каждой
+	// попыткой и возвращает функцию освобождения, вызываемую после неё. Ключ берётся из
+	// GateKey, а если он пуст — из контекста (WithGateKey). Ошибка прерывает цикл как у Gate.
+	// См. KeyedLimit.
+	HostGate func(ctx context.Context, key string) (release func(), err error)
+	GateKey  string
+
+	// ContextFn возвращает контекст для попытки attempt на основе базового ctx
+	// (например, добавляет номер попытки или сужает дедлайн). Если ContextFn создаёт
+	// контекст с отменой, освобождать его должен вызывающий код.
+	ContextFn func(ctx context.Context, attempt int) context.Context
+
+	// BeforeAttempt выполняет подготовку перед попыткой (обновление токена, новый ключ
+	// идемпотентности на повторах) и возвращает контекст попытки. Ошибка считается
+	// неповторяемой и прерывает цикл. Вызывается после Gate и до ContextFn.
+	BeforeAttempt func(ctx context.Context, attempt int) (context.Context, error)
+
+	// SuccessErrors — ошибки, означающие успех (например, ErrAlreadyExists): если ошибка
+	// операции соответствует одной из них по errors.Is, WithRetry возвращает результат без ошибки
+	SuccessErrors []error
+
+	// ErrorWrapper строит итоговую ошибку вместо *RetryError при исчерпании или прерывании
+	// попыток, чтобы встроить её в собственную иерархию ошибок
+	ErrorWrapper func(operation string, attempts int, lastErr error) error
+
+	RecordDelays bool // Сохранять фактические задержки (с jitter) в RetryError.Delays для отладки
+
+	ReportFirstError bool // Возвращать в RetryError.LastError ошибку первой попытки вместо последней
+
+	// FixedDelays задаёт точные задержки между попытками вместо экспоненциального backoff.
+	// Если MaxAttempts не задан, он равен len(FixedDelays)+1.
+	// Когда попыток больше, чем задержек, повторяется последняя задержка
+	// (или список проходится по кругу, если LoopFixedDelays = true).
+	FixedDelays     []time.Duration
+	LoopFixedDelays bool // Проходить FixedDelays по кругу вместо повтора последней задержки
+
+	// Backoff задаёт стратегию задержек (nil = экспоненциальная). BackoffName выбирает
+	// встроенную стратегию по имени (см. ParseBackoff), если Backoff не задан.
+	Backoff     BackoffStrategy
+	BackoffName string
+
+	// Jitter заменяет jitter по умолчанию: получает задержку стратегии без jitter
+	// и возвращает итоговую (в пределах [MinDelay, MaxDelay] или AbsoluteMaxDelay). См. DeterministicJitter.
+	Jitter func(attempt int, delay time.Duration) time.Duration
+
+	// StartupJitter задерживает первую попытку на случайное время из [0, StartupJitter],
+	// чтобы одновременно стартовавшие экземпляры не обращались к зависимости синхронно.
+	// Разброс берётся из Jitter (для attempt = 0), поэтому DeterministicJitter делает его воспроизводимым.
+	// Задержка входит в OperationTimeout.
+	StartupJitter time.Duration
+
+	// AbsoluteMaxDelay — жёсткий потолок итоговой задержки, независимый от MaxDelay, который
+	// ограничивает задержку стратегии до jitter. Применяется последним: после jitter, множителя
+	// Classify, FixedDelays, подсказки DelayHinter, DelayHook и DelayRounding; сдвиг очереди Pacer
+	// добавляется сверх него (0 = без отдельного потолка)
+	AbsoluteMaxDelay time.Duration
+
+	// MaxExponent ограничивает показатель экспоненциального backoff: задержка перестаёт
+	// расти на MinDelay*2^MaxExponent, даже если MaxDelay больше (0 = без ограничения)
+	MaxExponent int
+
+	// OnRetry вызывается непосредственно перед ожиданием повтора с номером неудачной попытки,
+	// лимитом попыток (-1 для бесконечных циклов, например Forever), задержкой и ошибкой.
+	// Удобен для вывода прогресса вида "attempt 2 of 5, retrying in 1s".
+	OnRetry func(attempt, maxAttempts int, delay time.Duration, err error)
+
+	// PersistState вызывается после планирования каждого повтора с состоянием, достаточным для
+	// возобновления после падения процесса через StartAttempt. Ошибка сохранения пишется в лог,
+	// попытки продолжаются.
+	PersistState func(state RetryState) error
+
+	// Wake досрочно завершает текущее ожидание: получение значения (или закрытие канала)
+	// запускает следующую попытку сразу, например когда health-checker видит восстановление
+	Wake <-chan struct{}
+
+	// DelayHook преобразует вычисленную задержку перед ожиданием. Вызывается после применения
+	// jitter и подсказки DelayHinter; результат ограничивается MaxDelay (или AbsoluteMaxDelay), 0 означает повтор без ожидания.
+	DelayHook func(attempt int, proposed time.Duration) time.Duration
+
+	// DelayRounding округляет итоговую задержку (после jitter и DelayHook) до ближайшего кратного,
+	// не меньше MinDelay, чтобы runtime мог объединять таймеры (0 = без округления)
+	DelayRounding time.Duration
+
+	// Classify заменяет ShouldRetry, дополнительно возвращая множитель задержки для ошибки
+	// (например, дольше ждать при 429). Множитель <= 0 считается равным 1.
+	Classify func(error) (retry bool, multiplier float64)
+
+	// ExtraRetriableStatus — дополнительные повторяемые HTTP статусы для этого вызова
+	// (например, 409 у конкретного API) поверх 5xx и 429
+	ExtraRetriableStatus []int
+
+	// MaxAttemptsFor задаёт лимит попыток для класса ошибки: положительное значение заменяет
+	// MaxAttempts для этой ошибки (например, 10 для 429), 0 оставляет MaxAttempts.
+	// Ошибки с одинаковым лимитом считаются одним классом, их неудачи считаются отдельно
+	// и не расходуют MaxAttempts.
+	MaxAttemptsFor func(error) int
+
+	// GiveUpOnRepeat прекращает попытки с Reason = RepeatedError, если одна и та же повторяемая
+	// ошибка получена столько раз подряд: повторы не продвигают операцию (0 = не проверять)
+	GiveUpOnRepeat int
+	// ErrorsEqual сравнивает ошибки соседних попыток для GiveUpOnRepeat, например только по
+	// статусу, когда тексты различаются отметкой времени (nil = errors.Is(текущая, предыдущая))
+	ErrorsEqual func(a, b error) bool
+
+	// CountAttempt решает, засчитывается ли неудачная попытка в MaxAttempts и MaxAttemptsFor,
+	// по её ошибке и длительности: например, быстрые отказы соединения можно повторять чаще,
+	// чем медленные таймауты (nil = засчитываются все). Незасчитанные попытки ограничены
+	// только бюджетом времени и контекстом, поэтому RequireBound требует с ним границы по времени.
+	CountAttempt func(err error, duration time.Duration) bool
+
+	// ZeroResultOnError возвращает вместе с RetryError нулевое значение вместо результата последней
+	// попытки. По умолчанию возвращается результат последней попытки (например, частично полученные данные).
+	ZeroResultOnError bool
 }
 
+// ErrNilOperation возвращается, если вместо операции передан nil
+var ErrNilOperation = errors.New("retry: operation function is nil")
+
+// ErrUnbounded возвращается при RequireBound, если повторные попытки ничем явно не ограничены
+var ErrUnbounded = errors.New("retry: no explicit bound (MaxAttempts, MaxElapsedTime, Deadline or context deadline)")
+
+// ErrOperationTimeout — причина (context.Cause) отмены контекста по истечении OperationTimeout.
+// Код, который сам выводит контекст операции (например, retryhttp.Transport, чей ответ
+// читается после возврата), задаёт её причиной, и WithRetry оформляет истечение так же,
+// как собственный OperationTimeout.
+var ErrOperationTimeout = errors.New("retry: operation timeout exceeded")
+
+// Reason описывает, почему WithRetry прекратил попытки
+type Reason string
+
+const (
+	AttemptsExhausted  Reason = "attempts_exhausted"   // Исчерпаны попытки, последняя ошибка была повторяемой
+	NonRetriable       Reason = "non_retriable"        // Ошибка признана неповторяемой
+	TimeBudgetExceeded Reason = "time_budget_exceeded" // Бюджет времени не вмещает следующую попытку
+	BudgetExhausted    Reason = "budget_exhausted"     // Исчерпан общий бюджет повторов Budget
+	QueueFull          Reason = "queue_full"           // Заполнена очередь повторов Pacer
+	RepeatedError      Reason = "repeated_error"       // Одна и та же ошибка подряд GiveUpOnRepeat раз
+)
+
+// ErrSlowAttempt — ошибка попытки, которая завершилась успешно, но медленнее RetryIfSlowerThan.
+// Всегда считается повторяемой.
+var ErrSlowAttempt = errors.New("retry: attempt slower than threshold")
+
 // RetryError представляет ошибку после всех неудачных попыток
 type RetryError struct {
-	Operation string
-	Attempts  int
-	LastError error
+	Operation  string
+	Attempts   int
+	Reason     Reason // Причина прекращения попыток
+	LastError  error  // Последняя ошибка (первая при ReportFirstError)
+	FirstError error  // Ошибка первой попытки
+
+	// Классификация ошибок неудачных попыток: только повторяемые ошибки указывают на
+	// временный сбой, неповторяемая (не больше одной, она прекращает попытки) — на системный
+	RetriableErrors    int
+	NonRetriableErrors int
+
+	Elapsed time.Duration   // Общее время выполнения, включая ожидания
+	Delays  []time.Duration // Фактические задержки между попытками (только при RecordDelays)
 }
 
 func (e *RetryError) Error() string {
+	// Первая ошибка часто указывает на настоящую причину, поэтому показываем обе
+	if e.FirstError != nil && e.LastError != nil && e.FirstError.Error() != e.LastError.Error() {
+		return fmt.Sprintf("operation '%s' failed after %d attempts (first: %v, last: %v)",
+			e.Operation, e.Attempts, e.FirstError, e.LastError)
+	}
 	return fmt.Sprintf("operation '%s' failed after %d attempts: %v",
 		e.Operation, e.Attempts, e.LastError)
 }
 
+// MarshalJSON сериализует ошибку для структурированных логов и ответов API
+func (e *RetryError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Operation    string `json:"operation"`
+		Attempts     int    `json:"attempts"`
+		Reason       Reason `json:"reason,omitempty"`
+		LastError    string `json:"last_error,omitempty"`
+		FirstError   string `json:"first_error,omitempty"`
+		Retriable    int    `json:"retriable_errors"`
+		NonRetriable int    `json:"non_retriable_errors"`
+		TotalElapsed string `json:"total_elapsed"`
+	}{
+		Operation:    e.Operation,
+		Attempts:     e.Attempts,
+		Reason:       e.Reason,
+		LastError:    errorString(e.LastError),
+		FirstError:   errorString(e.FirstError),
+		Retriable:    e.RetriableErrors,
+		NonRetriable: e.NonRetriableErrors,
+		TotalElapsed: e.Elapsed.String(),
+	})
+}
+
 func (e *RetryError) Unwrap() error {
 	return e.LastError
 }
 
+// RetryState — состояние запланированного повтора для RetryConfig.PersistState
+type RetryState struct {
+	Operation   string
+	NextAttempt int           // Номер следующей попытки, подходит для StartAttempt
+	Delay       time.Duration // Задержка перед следующей попыткой
+	LastError   error         // Ошибка неудачной попытки
+}
+
+// DelayHinter реализуется ошибками, которые сами подсказывают задержку перед следующей попыткой
+// (например, HTTPError с заголовком Retry-After). Подсказка ограничивается MaxDelay.
+type DelayHinter interface {
+	DelayHint() (time.Duration, bool)
+}
+
 // WithRetry выполняет операцию с экспоненциальным backoff и повторными попытками.
 func WithRetry[T any](
 	ctx context.Context,
@@ -51,84 +313,706 @@ func WithRetry[T any](
 	operationName string,
 	operationFn func(context.Context) (T, error),
 ) (T, error) {
-	// Устанавливаем значения по умолчанию
-	if config.MaxAttempts <= 0 {
-		config.MaxAttempts = DefaultMaxAttempts
+	if operationFn == nil {
+		var zero T
+		return zero, ErrNilOperation
 	}
-	if config.MinDelay <= 0 {
-		config.MinDelay = DefaultMinDelay
+	if config.RequireBound && !hasBound(ctx, config) {
+		var zero T
+		return zero, ErrUnbounded
 	}
-	if config.MaxDelay <= 0 {
-		config.MaxDelay = DefaultMaxDelay
+
+	if config.Backoff == nil && config.BackoffName != "" {
+		if _, err := ParseBackoff(config.BackoffName); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+
+	config = withDefaults(config)
+	if n, ok := MaxAttemptsFrom(ctx); ok {
+		config.MaxAttempts = n
 	}
-	if config.ShouldRetry == nil {
-		config.ShouldRetry = shouldRetryError
+	if Disabled(ctx) {
+		config.MaxAttempts = config.StartAttempt
+		config.MaxAttemptsFor = nil
+		config.CountAttempt = nil
+	}
+
+	// OperationTimeout ограничивает весь цикл: StartupJitter, попытки и ожидания
+	if config.OperationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, config.OperationTimeout, ErrOperationTimeout)
+		defer cancel()
 	}
 
 	var result T
-	var lastErr error
+	if config.StartupJitter > 0 {
+		select {
+		case <-ctx.Done():
+			// Истечение OperationTimeout оформляется в цикле как исчерпание бюджета времени
+			if !operationTimedOut(ctx) {
+				return result, ctx.Err()
+			}
+		case <-config.Clock.After(startupDelay(config)):
+		}
+	}
 
-	for attempt := 1; attempt <= config.MaxAttempts; attempt++ {
-		result, lastErr = operationFn(ctx)
-		if lastErr == nil {
-			if attempt > 1 && config.Logger != nil {
-				config.Logger.Info("Operation succeeded after retry",
+	var lastErr, firstErr error
+	var reason Reason
+	start := config.Clock.Now()
+	deadline := budgetDeadline(ctx, config, start)
+	attempts := 0
+
+	counted := config.StartAttempt - 1 // Неудачи вне классов MaxAttemptsFor, засчитанные в MaxAttempts (см. CountAttempt)
+	var classAttempts map[int]int      // Неудачи по классам MaxAttemptsFor (создаётся при первой)
+	var delays []time.Duration         // Фактические задержки при RecordDelays
+
+	// Слот SetMaxConcurrentRetries освобождается после повторной попытки или при любом выходе из цикла
+	var releaseSlot func()
+	defer func() {
+		if releaseSlot != nil {
+			releaseSlot()
+		}
+	}()
+	retriableErrs, nonRetriableErrs := 0, 0
+	var prevErr error // Ошибка предыдущей попытки для GiveUpOnRepeat
+	repeats := 0
+
+	// Последняя попытка — медленный успех (RetryIfSlowerThan). Если его необязательный
+	// повтор прерывается, цикл завершается успехом: без логов прерывания и с событием успеха
+	slow := false
+	succeed := func(attempt int) (T, error) {
+		emitEvent(config, Event{Type: EventSuccess, Operation: operationName, Attempt: attempt})
+		if config.Metrics != nil {
+			config.Metrics.Success(operationName, attempt)
+		}
+		if attempt > config.StartAttempt && !config.DisableSuccessLog {
+			logAttrs(ctx, config, slog.LevelInfo, "Operation succeeded after retry",
+				slog.String("operation", operationName),
+				slog.Int("attempt", attempt))
+		}
+		return result, nil
+	}
+	logAbort := func(level slog.Level, msg string, attrs ...slog.Attr) {
+		if !slow {
+			logAttrs(ctx, config, level, msg, attrs...)
+		}
+	}
+
+	for attempt := config.StartAttempt; ; attempt++ {
+		// Прошедший Deadline или истёкший за StartupJitter OperationTimeout не оставляют
+		// времени и на первую попытку: операция не запускается
+		if attempt == config.StartAttempt && (!config.Deadline.IsZero() && !start.Before(config.Deadline) ||
+			ctx.Err() != nil && operationTimedOut(ctx)) {
+			logAttrs(ctx, config, slog.LevelWarn, "Retry aborted due to exceeded time budget",
+				slog.String("operation", operationName),
+				slog.Int("attempt", attempt),
+				slog.Duration("remaining", 0))
+			lastErr = context.DeadlineExceeded
+			reason = TimeBudgetExceeded
+			break
+		}
+
+		if config.Gate != nil {
+			if err := config.Gate(ctx); err != nil {
+				logAbort(slog.LevelWarn, "Retry aborted by gate",
 					slog.String("operation", operationName),
-					slog.Int("attempt", attempt))
+					slog.Int("attempt", attempt),
+					slog.Any("error", err))
+				if slow {
+					return succeed(attempt - 1)
+				}
+				return result, err
 			}
-			return result, nil
 		}
 
-		// Проверка — повторять ли эту ошибку
-		if config.ShouldRetry != nil && !config.ShouldRetry(lastErr) {
-			if config.Logger != nil {
-				config.Logger.Warn("Retry aborted due to non-retriable error",
+		attempts = attempt
+		attemptCtx := ctx
+		if config.BeforeAttempt != nil {
+			hookCtx, err := config.BeforeAttempt(ctx, attempt)
+			if err != nil {
+				logAbort(slog.LevelWarn, "Retry aborted by BeforeAttempt hook",
 					slog.String("operation", operationName),
 					slog.Int("attempt", attempt),
-					slog.Any("error", lastErr))
+					slog.Any("error", err))
+				if slow {
+					return succeed(attempt - 1)
+				}
+				lastErr = err
+				if firstErr == nil {
+					firstErr = err
+				}
+				nonRetriableErrs++
+				reason = NonRetriable
+				break
+			}
+			attemptCtx = hookCtx
+		}
+		if config.ContextFn != nil {
+			attemptCtx = config.ContextFn(attemptCtx, attempt)
+		}
+
+		var releaseHost func()
+		if config.HostGate != nil {
+			key := config.GateKey
+			if key == "" {
+				key = GateKeyFrom(ctx)
+			}
+			release, err := config.HostGate(ctx, key)
+			if err != nil {
+				logAbort(slog.LevelWarn, "Retry aborted by host gate",
+					slog.String("operation", operationName),
+					slog.String("key", key),
+					slog.Int("attempt", attempt),
+					slog.Any("error", err))
+				if slow {
+					return succeed(attempt - 1)
+				}
+				return result, err
+			}
+			releaseHost = release
+		}
+
+		emitEvent(config, Event{Type: EventAttemptStart, Operation: operationName, Attempt: attempt})
+		attemptStart := config.Clock.Now()
+		result, lastErr = operationFn(attemptCtx)
+		if releaseHost != nil {
+			releaseHost()
+		}
+		if releaseSlot != nil {
+			releaseSlot()
+			releaseSlot = nil
+		}
+		attemptDuration := config.Clock.Now().Sub(attemptStart)
+		totalAttempts.Add(1)
+		if config.Metrics != nil {
+			config.Metrics.Attempt(operationName, attemptDuration, lastErr)
+		}
+		// Успешный путь не вызывает stopped: errors.As в ней выделяет память
+		if lastErr != nil {
+			if err, ok := stopped(lastErr); ok {
+				emitEvent(config, Event{Type: EventFailure, Operation: operationName, Attempt: attempt, Err: err})
+				logAttrs(ctx, config, slog.LevelWarn, "Retry stopped by operation",
+					slog.String("operation", operationName),
+					slog.Int("attempt", attempt),
+					slog.Any("error", err))
+				return result, err
+			}
+		}
+		if lastErr != nil && isSuccessError(config, lastErr) {
+			lastErr = nil
+		}
+		// Слишком медленный успех повторяем, пока остаются попытки
+		slow = lastErr == nil && config.RetryIfSlowerThan > 0 && attemptDuration > config.RetryIfSlowerThan &&
+			counted+1 < config.MaxAttempts
+		if slow {
+			lastErr = ErrSlowAttempt
+		}
+		if lastErr == nil {
+			return succeed(attempt)
+		}
+		emitEvent(config, Event{Type: EventFailure, Operation: operationName, Attempt: attempt, Err: lastErr})
+		if firstErr == nil {
+			firstErr = lastErr
+		}
+
+		// Контекст вызывающего отменён — дальше не повторяем, даже если ошибка повторяемая.
+		// Истечение OperationTimeout оформляется как исчерпание бюджета времени. Медленный
+		// успех (ErrSlowAttempt) остаётся успехом: прерывается лишь его необязательный повтор
+		if ctx.Err() != nil {
+			if slow {
+				return succeed(attempt)
+			}
+			if operationTimedOut(ctx) {
+				lastErr = canceledError(ctx, lastErr)
+				reason = TimeBudgetExceeded
+				break
 			}
+			return result, canceledError(ctx, lastErr)
+		}
+
+		// Проверка — повторять ли эту ошибку. Выполняется и на последней попытке,
+		// чтобы Reason различал исчерпание попыток и неповторяемую ошибку
+		retriable, multiplier := classify(ctx, config, lastErr)
+		if !retriable {
+			nonRetriableErrs++
+			logAttrs(ctx, config, slog.LevelWarn, "Retry aborted due to non-retriable error",
+				slog.String("operation", operationName),
+				slog.Int("attempt", attempt),
+				slog.Any("error", lastErr))
+			reason = NonRetriable
 			break
 		}
+		retriableErrs++
 
-		if config.Logger != nil {
-			config.Logger.Error("Operation failed, will retry",
+		if config.GiveUpOnRepeat > 0 {
+			if prevErr != nil && errorsEqual(config, lastErr, prevErr) {
+				repeats++
+			} else {
+				repeats = 1
+			}
+			prevErr = lastErr
+			if repeats >= config.GiveUpOnRepeat {
+				logAbort(slog.LevelWarn, "Retry aborted due to repeated error",
+					slog.String("operation", operationName),
+					slog.Int("attempt", attempt),
+					slog.Int("repeats", repeats),
+					slog.Any("error", lastErr))
+				reason = RepeatedError
+				break
+			}
+		}
+
+		// CountAttempt может не засчитывать попытку в лимит (например, мгновенный отказ соединения)
+		countThis := config.CountAttempt == nil || config.CountAttempt(lastErr, attemptDuration)
+
+		// Для классов MaxAttemptsFor попытки считаются отдельно, остальные неудачи — в counted
+		maxAttempts := config.MaxAttempts
+		classLimit := 0
+		if config.MaxAttemptsFor != nil {
+			classLimit = config.MaxAttemptsFor(lastErr)
+		}
+		var exhausted bool
+		if classLimit > 0 {
+			if countThis {
+				if classAttempts == nil {
+					classAttempts = make(map[int]int)
+				}
+				classAttempts[classLimit]++
+			}
+			maxAttempts = classLimit
+			exhausted = classAttempts[classLimit] >= classLimit
+		} else {
+			if countThis {
+				counted++
+			}
+			exhausted = counted >= maxAttempts
+		}
+		exhausted = exhausted || !RetriesEnabled()
+
+		if exhausted {
+			logAbort(slog.LevelError, "Operation failed, attempts exhausted",
 				slog.String("operation", operationName),
 				slog.Int("attempt", attempt),
-				slog.Int("max_attempt", config.MaxAttempts),
+				slog.Int("max_attempt", maxAttempts),
 				slog.Any("error", lastErr))
+			reason = AttemptsExhausted
+			break
 		}
 
-		if attempt == config.MaxAttempts {
+		// Общий бюджет повторов проверяется перед каждым повтором
+		if config.Budget != nil && !config.Budget.allow(config.Clock.Now()) {
+			logAbort(slog.LevelWarn, "Retry aborted due to exhausted retry budget",
+				slog.String("operation", operationName),
+				slog.Int("attempt", attempt),
+				slog.Any("error", lastErr))
+			reason = BudgetExhausted
 			break
 		}
 
-		delay := backoff.CalculateExponentialBackoff(attempt, config.MinDelay, config.MaxDelay)
+		delay := nextDelay(config, attempt, config.Clock.Now().Sub(start), lastErr, multiplier)
 
-		select {
-		case <-ctx.Done():
-			return result, ctx.Err()
-		case <-time.After(delay):
+		// Pacer сдвигает повтор на ближайшее свободное окно очереди
+		if config.Pacer != nil {
+			now := config.Clock.Now()
+			slot, ok := config.Pacer.reserve(now, now.Add(delay))
+			if !ok {
+				logAbort(slog.LevelWarn, "Retry aborted due to full retry queue",
+					slog.String("operation", operationName),
+					slog.Int("attempt", attempt),
+					slog.Any("error", lastErr))
+				reason = QueueFull
+				break
+			}
+			delay = slot.Sub(now)
+		}
+
+		// Урезаем задержку до остатка бюджета времени, оставляя запас на саму попытку.
+		// Если остаток меньше запаса, следующая попытка обречена — прекращаем сразу.
+		if remaining, ok := remainingBudget(config, start, deadline); ok {
+			reserve := max(config.MinRemainingBudget, 0)
+			if remaining <= 0 || remaining < reserve {
+				logAbort(slog.LevelWarn, "Retry aborted due to exceeded time budget",
+					slog.String("operation", operationName),
+					slog.Int("attempt", attempt),
+					slog.Duration("remaining", max(remaining, 0)))
+				if config.OperationTimeout > 0 && !errors.Is(lastErr, context.DeadlineExceeded) {
+					lastErr = errors.Join(context.DeadlineExceeded, lastErr)
+				}
+				reason = TimeBudgetExceeded
+				break
+			}
+			delay = min(delay, remaining-reserve)
+		}
+
+		logAttrs(ctx, config, slog.LevelError, "Operation failed, will retry",
+			slog.String("operation", operationName),
+			slog.Int("attempt", attempt),
+			slog.Int("next_attempt", attempt+1),
+			slog.Int("max_attempt", maxAttempts),
+			slog.Duration("delay", delay),
+			slog.Any("error", lastErr))
+
+		totalRetries.Add(1)
+		if config.Metrics != nil {
+			config.Metrics.Retry(operationName, attempt)
+		}
+		if config.RecordDelays {
+			delays = append(delays, delay)
+		}
+		if config.OnRetry != nil {
+			config.OnRetry(attempt, maxAttempts, delay, lastErr)
+		}
+		if config.PersistState != nil {
+			state := RetryState{Operation: operationName, NextAttempt: attempt + 1, Delay: delay, LastError: lastErr}
+			if err := config.PersistState(state); err != nil {
+				logAttrs(ctx, config, slog.LevelWarn, "Failed to persist retry state",
+					slog.String("operation", operationName),
+					slog.Int("attempt", attempt),
+					slog.Any("error", err))
+			}
+		}
+		emitEvent(config, Event{Type: EventSleep, Operation: operationName, Attempt: attempt, Delay: delay})
+
+		// Слот фазы повтора удерживается на время ожидания и следующей попытки.
+		// Ошибка означает отмену контекста, она обрабатывается ниже
+		if release, err := acquireRetrySlot(ctx); err == nil {
+			releaseSlot = release
+			select {
+			case <-ctx.Done():
+			case <-config.Clock.After(delay):
+			case <-config.Wake:
+			}
+		}
+
+		if ctx.Err() != nil {
+			if slow {
+				return succeed(attempt)
+			}
+			if operationTimedOut(ctx) {
+				lastErr = canceledError(ctx, lastErr)
+				reason = TimeBudgetExceeded
+				break
+			}
+			return result, canceledError(ctx, lastErr)
 		}
 	}
 
-	return result, &RetryError{
+	// Повтор медленного успеха не состоялся (исчерпаны бюджеты, повторы и т. п.) —
+	// отдаём последний результат как успешный
+	if slow {
+		return succeed(attempts)
+	}
+
+	if config.ReportFirstError {
+		lastErr = firstErr
+	}
+	totalGiveUps.Add(1)
+	if config.Metrics != nil {
+		config.Metrics.GiveUp(operationName, attempts, reason)
+	}
+	emitEvent(config, Event{
+		Type:      EventGiveUp,
 		Operation: operationName,
-		Attempts:  config.MaxAttempts,
-		LastError: lastErr,
+		Attempt:   attempts,
+		Err:       lastErr,
+		Reason:    reason,
+	})
+
+	if config.ZeroResultOnError {
+		var zero T
+		result = zero
+	}
+	if config.ErrorWrapper != nil {
+		return result, config.ErrorWrapper(operationName, attempts, lastErr)
+	}
+
+	return result, &RetryError{
+		Operation:          operationName,
+		Attempts:           attempts,
+		Reason:             reason,
+		LastError:          lastErr,
+		FirstError:         firstErr,
+		RetriableErrors:    retriableErrs,
+		NonRetriableErrors: nonRetriableErrs,
+		Elapsed:            config.Clock.Now().Sub(start),
+		Delays:             delays,
 	}
 }
 
+// WithRetrySteps работает как WithRetry, но на каждой попытке выполняет свой шаг:
+// попытка N выполняет steps[min(N-1, len(steps)-1)], то есть последний шаг повторяется,
+// если попыток больше, чем шагов (например, для постепенно упрощаемых запросов).
+func WithRetrySteps[T any](
+	ctx context.Context,
+	config RetryConfig,
+	operationName string,
+	steps ...func(context.Context) (T, error),
+) (T, error) {
+	var zero T
+	if len(steps) == 0 {
+		return zero, errors.New("retry: no steps")
+	}
+	for _, fn := range steps {
+		if fn == nil {
+			return zero, ErrNilOperation
+		}
+	}
+
+	// Номер попытки передаётся шагу через контекст попытки, поэтому шаг определяется
+	// номером попытки (в том числе при StartAttempt), а не числом вызовов
+	contextFn := config.ContextFn
+	config.ContextFn = func(ctx context.Context, attempt int) context.Context {
+		if contextFn != nil {
+			ctx = contextFn(ctx, attempt)
+		}
+		return context.WithValue(ctx, stepAttemptKey{}, attempt)
+	}
+	return WithRetry(ctx, config, operationName, func(ctx context.Context) (T, error) {
+		attempt, _ := ctx.Value(stepAttemptKey{}).(int)
+		return steps[min(max(attempt, 1), len(steps))-1](ctx)
+	})
+}
+
+type stepAttemptKey struct{}
+
+// Must возвращает value или паникует, если err != nil. Предназначена для обёртки
+// WithRetry в коде инициализации: cfg := retry.Must(retry.WithRetry(...)).
+// Паника содержит ошибку целиком, включая детали RetryError.
+func Must[T any](value T, err error) T {
+	if err != nil {
+		panic(fmt.Errorf("retry: %w", err))
+	}
+	return value
+}
+
+// errorString возвращает текст ошибки или пустую строку для nil
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// operationTimedOut сообщает, что контекст отменён истечением OperationTimeout,
+// а не вызывающим
+func operationTimedOut(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrOperationTimeout)
+}
+
+// canceledError возвращает ошибку отмены контекста вместе с последней ошибкой операции,
+// чтобы была видна причина повторов; errors.Is находит обе
+func canceledError(ctx context.Context, lastErr error) error {
+	if lastErr == nil || errors.Is(lastErr, ctx.Err()) {
+		return ctx.Err()
+	}
+	return errors.Join(ctx.Err(), lastErr)
+}
+
+// hasBound проверяет, что повторные попытки явно ограничены конфигурацией или контекстом.
+// Лимит попыток задают MaxAttempts, FixedDelays (len+1 попыток) и WithMaxAttempts контекста;
+// с CountAttempt он не ограничивает незасчитанные попытки, поэтому нужна граница по времени.
+func hasBound(ctx context.Context, config RetryConfig) bool {
+	if Disabled(ctx) {
+		return true
+	}
+	if config.CountAttempt == nil {
+		if _, ok := MaxAttemptsFrom(ctx); ok || config.MaxAttempts > 0 || len(config.FixedDelays) > 0 {
+			return true
+		}
+	}
+	if config.MaxElapsedTime > 0 || config.OperationTimeout > 0 || !config.Deadline.IsZero() {
+		return true
+	}
+	_, ok := ctx.Deadline()
+	return ok
+}
+
+// budgetDeadline возвращает ближайшую из границ Deadline и дедлайна контекста на шкале Clock
+// (нулевое значение = границ нет). Дедлайн контекста задан по системному времени, поэтому
+// переносится на Clock остатком до него, отсчитанным от start.
+func budgetDeadline(ctx context.Context, config RetryConfig, start time.Time) time.Time {
+	deadline := config.Deadline
+	if ctxDeadline, ok := ctx.Deadline(); ok {
+		if moved := start.Add(time.Until(ctxDeadline)); deadline.IsZero() || moved.Before(deadline) {
+			deadline = moved
+		}
+	}
+	return deadline
+}
+
+// remainingBudget возвращает остаток времени до ближайшей из границ: MaxElapsedTime
+// от start или deadline (см. budgetDeadline). ok = false, если ни одна граница не задана.
+func remainingBudget(config RetryConfig, start, deadline time.Time) (time.Duration, bool) {
+	now := config.Clock.Now()
+	remaining, ok := time.Duration(0), false
+	if config.MaxElapsedTime > 0 {
+		remaining, ok = config.MaxElapsedTime-now.Sub(start), true
+	}
+	if !deadline.IsZero() {
+		if left := deadline.Sub(now); !ok || left < remaining {
+			remaining, ok = left, true
+		}
+	}
+	return remaining, ok
+}
+
+// withDefaults устанавливает значения по умолчанию для незаданных параметров
+func withDefaults(config RetryConfig) RetryConfig {
+	if config.MaxAttempts <= 0 {
+		if len(config.FixedDelays) > 0 {
+			config.MaxAttempts = len(config.FixedDelays) + 1
+		} else {
+			config.MaxAttempts = DefaultMaxAttempts
+		}
+	}
+	if config.MinDelay <= 0 {
+		config.MinDelay = DefaultMinDelay
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = DefaultMaxDelay
+	}
+	if config.MinRemainingBudget == 0 {
+		config.MinRemainingBudget = config.MinDelay
+	}
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+	if config.Backoff == nil && config.BackoffName != "" {
+		// Неизвестное имя оставляет экспоненциальный backoff; WithRetry проверяет его заранее
+		config.Backoff, _ = ParseBackoff(config.BackoffName)
+	}
+	if config.StartAttempt <= 0 {
+		config.StartAttempt = 1
+	}
+
+	return config
+}
+
+// errorsEqual сравнивает ошибки соседних попыток для GiveUpOnRepeat
+func errorsEqual(config RetryConfig, a, b error) bool {
+	if config.ErrorsEqual != nil {
+		return config.ErrorsEqual(a, b)
+	}
+	return errors.Is(a, b)
+}
+
+// isSuccessError сообщает, считается ли ошибка успехом по SuccessErrors
+func isSuccessError(config RetryConfig, err error) bool {
+	for _, target := range config.SuccessErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// WouldRetry сообщает, повторит ли WithRetry с этой конфигурацией ошибку err, не запуская цикл:
+// учитываются ShouldRetry (или классификатор по умолчанию), Classify и ExtraRetriableStatus.
+// Лимиты попыток и бюджеты не проверяются.
+func (c RetryConfig) WouldRetry(err error) bool {
+	if err == nil {
+		return false
+	}
+	retriable, _ := classify(context.Background(), withDefaults(c), err)
+	return retriable
+}
+
+// classify определяет, стоит ли повторять ошибку, и множитель задержки для неё
+func classify(ctx context.Context, config RetryConfig, err error) (bool, float64) {
+	if errors.Is(err, ErrSlowAttempt) || errors.Is(err, ErrConditionNotMet) {
+		return true, 1
+	}
+
+	var httpErr *HTTPError
+	if len(config.ExtraRetriableStatus) > 0 && errors.As(err, &httpErr) &&
+		slices.Contains(config.ExtraRetriableStatus, httpErr.StatusCode) {
+		return true, 1
+	}
+	if config.Classify == nil {
+		if config.ShouldRetry == nil {
+			return shouldRetryContext(ctx, err), 1
+		}
+		return config.ShouldRetry(err), 1
+	}
+
+	retriable, multiplier := config.Classify(err)
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	return retriable, multiplier
+}
+
+// IsCallerCanceled сообщает, вызвана ли ошибка err отменой или дедлайном именно контекста ctx
+// вызывающего, а не другого контекста (например, потока или отдельной попытки)
+func IsCallerCanceled(ctx context.Context, err error) bool {
+	ctxErr := ctx.Err()
+	if ctxErr == nil || err == nil {
+		return false
+	}
+	return errors.Is(err, ctxErr) || errors.Is(err, context.Cause(ctx))
+}
+
+// shouldRetryContext — классификатор по умолчанию с учётом контекста вызывающего: ошибка,
+// вызванная его отменой или дедлайном, не повторяется, даже если выглядит временной
+// (например, таймаут url.Error). Остальные ошибки классифицирует shouldRetryError.
+func shouldRetryContext(ctx context.Context, err error) bool {
+	if IsCallerCanceled(ctx, err) {
+		return false
+	}
+	return shouldRetryError(err)
+}
+
 // shouldRetryError определяет, стоит ли повторять операцию при данной ошибке
 func shouldRetryError(err error) bool {
 	if err == nil {
 		return false
 	}
 
+	// Объединённая ошибка (errors.Join): повторяем, если повторяема хотя бы одна составляющая,
+	// иначе отмена контекста или неповторяемый HTTP статус в одной из них скрыл бы остальные
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if shouldRetryError(e) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Таймаут HTTP клиента (url.Error поверх context.DeadlineExceeded) — повторяем.
+	// Отмена контекста вызывающего проверяется в WithRetry до вызова классификатора.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Timeout() {
+		return true
+	}
+
 	// Контекст отменён или дедлайн — не повторяем
 	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return false
 	}
 
+	// Ошибки проверки сертификата сами не исправятся — не повторяем, даже внутри url.Error
+	var certErr x509.CertificateInvalidError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &certErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) {
+		return false
+	}
+
+	// Повреждённая запись TLS бывает при ротации сертификатов и обрывах — повторяем.
+	// Таймаут рукопожатия обрабатывается ниже как net.Error с Timeout()
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &recordErr) {
+		return true
+	}
+
+	// Обрыв чтения и короткая запись обычно временные; io.EOF — штатный конец данных, не повторяем
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.ErrShortWrite) {
+		return true
+	}
+
 	// Проверяем сетевые ошибки
 	var netErr net.Error
 	if errors.As(err, &netErr) {
@@ -144,7 +1028,6 @@ func shouldRetryError(err error) bool {
 	}
 
 	// URL ошибки
-	var urlErr *url.Error
 	if errors.As(err, &urlErr) {
 		return true
 	}
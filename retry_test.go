@@ -0,0 +1,1249 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failing возвращает операцию, которая всегда завершается ошибкой err, и счётчик её вызовов
+func failing(err error) (func(context.Context) (int, error), *int) {
+	calls := 0
+	return func(context.Context) (int, error) {
+		calls++
+		return calls, err
+	}, &calls
+}
+
+// requireReason проверяет, что err — RetryError с причиной reason, и возвращает его
+func requireReason(t *testing.T, err error, reason Reason) *RetryError {
+	t.Helper()
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("error = %v, want *RetryError", err)
+	}
+	if retryErr.Reason != reason {
+		t.Fatalf("Reason = %s, want %s (error: %v)", retryErr.Reason, reason, err)
+	}
+	return retryErr
+}
+
+func TestOperationTimeoutDuringAttempt(t *testing.T) {
+	calls := 0
+	_, err := WithRetry(context.Background(), RetryConfig{OperationTimeout: 20 * time.Millisecond}, "op",
+		func(ctx context.Context) (int, error) {
+			calls++
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+
+	retryErr := requireReason(t, err, TimeBudgetExceeded)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if calls != 1 || retryErr.Attempts != 1 {
+		t.Fatalf("calls = %d, Attempts = %d, want 1", calls, retryErr.Attempts)
+	}
+}
+
+func TestOperationTimeoutDuringSleep(t *testing.T) {
+	// Часы не сдвигаются, поэтому ожидание прерывает только истечение OperationTimeout
+	clock := newManualClock()
+	op, calls := failing(errRetriable)
+	_, err := WithRetry(context.Background(), RetryConfig{
+		Clock:              clock,
+		OperationTimeout:   20 * time.Millisecond,
+		MinRemainingBudget: -1,
+	}, "op", op)
+
+	requireReason(t, err, TimeBudgetExceeded)
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errRetriable) {
+		t.Fatalf("error = %v, want context.DeadlineExceeded and the last attempt error", err)
+	}
+	if *calls != 1 {
+		t.Fatalf("calls = %d, want 1", *calls)
+	}
+	if len(clock.waiting) != 1 {
+		t.Fatalf("sleeps = %d, want 1", len(clock.waiting))
+	}
+}
+
+func TestCancelDuringSleepKeepsLastError(t *testing.T) {
+	// Отмена во время ожидания возвращает context.Canceled вместе с ошибкой последней попытки
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := newManualClock()
+	go func() {
+		<-clock.waiting
+		cancel()
+	}()
+
+	lastErr := &HTTPError{StatusCode: 502, Message: "Bad Gateway"}
+	op, calls := failing(lastErr)
+	_, err := WithRetry(ctx, RetryConfig{Clock: clock, MaxAttempts: 5}, "op", op)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != 502 {
+		t.Fatalf("error = %v, want the last attempt error reachable with errors.As", err)
+	}
+	var retryErr *RetryError
+	if errors.As(err, &retryErr) {
+		t.Fatalf("error = %v, want cancellation, not *RetryError", err)
+	}
+	if *calls != 1 {
+		t.Fatalf("calls = %d, want 1", *calls)
+	}
+}
+
+func TestOperationTimeoutCause(t *testing.T) {
+	// Контекст, выведенный с причиной ErrOperationTimeout, оформляется как OperationTimeout
+	ctx, cancel := context.WithTimeoutCause(context.Background(), 20*time.Millisecond, ErrOperationTimeout)
+	defer cancel()
+	_, err := WithRetry(ctx, RetryConfig{}, "op", func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	requireReason(t, err, TimeBudgetExceeded)
+}
+
+func TestDefaultClassifierCallerCancellation(t *testing.T) {
+	alive := context.Background()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	timeout := &url.Error{Op: "Get", URL: "http://example.com", Err: context.DeadlineExceeded}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"server disconnect mid-stream", alive, fmt.Errorf("read body: %w", io.ErrUnexpectedEOF), true},
+		{"attempt timeout, caller alive", alive, timeout, true},
+		{"bare context error, caller alive", alive, context.Canceled, false},
+		{"caller canceled", canceled, fmt.Errorf("read body: %w", context.Canceled), false},
+		{"caller deadline inside url.Error", expired, timeout, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := classify(tt.ctx, RetryConfig{}, tt.err); got != tt.want {
+				t.Fatalf("classify(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+
+	if (RetryConfig{}).WouldRetry(context.Canceled) {
+		t.Fatalf("WouldRetry(context.Canceled) = true, want false")
+	}
+}
+
+func TestIsCallerCanceled(t *testing.T) {
+	caller, cancel := context.WithCancelCause(context.Background())
+	other, cancelOther := context.WithCancel(context.Background())
+	cancelOther()
+
+	if IsCallerCanceled(caller, other.Err()) {
+		t.Fatalf("IsCallerCanceled() = true before the caller is canceled")
+	}
+
+	errShutdown := errors.New("shutdown")
+	cancel(errShutdown)
+	if !IsCallerCanceled(caller, fmt.Errorf("stream: %w", context.Canceled)) {
+		t.Fatalf("IsCallerCanceled(ctx.Err()) = false, want true")
+	}
+	if !IsCallerCanceled(caller, errShutdown) {
+		t.Fatalf("IsCallerCanceled(cause) = false, want true")
+	}
+	if IsCallerCanceled(caller, io.ErrUnexpectedEOF) {
+		t.Fatalf("IsCallerCanceled(io.ErrUnexpectedEOF) = true, want false")
+	}
+}
+
+func TestResultOnError(t *testing.T) {
+	partial := func(context.Context) ([]string, error) {
+		return []string{"partial"}, errRetriable
+	}
+	config := RetryConfig{Clock: newFakeClock(), MaxAttempts: 2}
+
+	// По умолчанию возвращается результат последней попытки
+	result, err := WithRetry(context.Background(), config, "op", partial)
+	requireReason(t, err, AttemptsExhausted)
+	if len(result) != 1 || result[0] != "partial" {
+		t.Fatalf("result = %v, want the last partial result", result)
+	}
+
+	config.ZeroResultOnError = true
+	result, err = WithRetry(context.Background(), config, "op", partial)
+	requireReason(t, err, AttemptsExhausted)
+	if result != nil {
+		t.Fatalf("result = %v, want zero value with ZeroResultOnError", result)
+	}
+
+	step := Step(context.Background(), config, "op", 2, partial)
+	if !step.Done || step.Value != nil {
+		t.Fatalf("Step() = %+v, want Done with zero value", step)
+	}
+}
+
+func TestMinRemainingBudgetBoundary(t *testing.T) {
+	tests := []struct {
+		name      string
+		reserve   time.Duration // MinRemainingBudget (0 = MinDelay)
+		attempt   time.Duration // Длительность первой попытки при MaxElapsedTime 10s
+		wantCalls int
+		wantSleep time.Duration
+	}{
+		{"below threshold", 2 * time.Second, 8*time.Second + 1, 1, 0},
+		{"at threshold", 2 * time.Second, 8 * time.Second, 2, 0},
+		{"below default MinDelay", 0, 9*time.Second + 1, 1, 0},
+		{"at default MinDelay", 0, 9 * time.Second, 2, 0},
+		{"disabled", -1, 9*time.Second + 500*time.Millisecond, 2, 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			calls := 0
+			_, err := WithRetry(context.Background(), RetryConfig{
+				Clock:              clock,
+				MaxAttempts:        2,
+				MinDelay:           time.Second,
+				MaxDelay:           time.Second,
+				Jitter:             noJitter,
+				MaxElapsedTime:     10 * time.Second,
+				MinRemainingBudget: tt.reserve,
+			}, "op", func(context.Context) (int, error) {
+				calls++
+				if calls == 1 {
+					clock.Advance(tt.attempt)
+				}
+				return 0, errRetriable
+			})
+
+			if calls != tt.wantCalls {
+				t.Fatalf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantCalls == 1 {
+				requireReason(t, err, TimeBudgetExceeded)
+				if sleeps := clock.Sleeps(); len(sleeps) != 0 {
+					t.Fatalf("sleeps = %v, want none before giving up", sleeps)
+				}
+				return
+			}
+			requireReason(t, err, AttemptsExhausted)
+			if sleeps := clock.Sleeps(); len(sleeps) != 1 || sleeps[0] != tt.wantSleep {
+				t.Fatalf("sleeps = %v, want [%s]", sleeps, tt.wantSleep)
+			}
+		})
+	}
+}
+
+func TestRequireBound(t *testing.T) {
+	neverCounted := func(error, time.Duration) bool { return false }
+	expiring, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		config RetryConfig
+		want   bool
+	}{
+		{"no bound", context.Background(), RetryConfig{}, false},
+		{"max attempts", context.Background(), RetryConfig{MaxAttempts: 3}, true},
+		{"max attempts from context", WithMaxAttempts(context.Background(), 2), RetryConfig{}, true},
+		{"max attempts from FixedDelays", context.Background(), RetryConfig{FixedDelays: []time.Duration{time.Second}}, true},
+		{"retries disabled", WithDisabled(context.Background()), RetryConfig{}, true},
+		{"context max attempts with CountAttempt", WithMaxAttempts(context.Background(), 2), RetryConfig{CountAttempt: neverCounted}, false},
+		{"max attempts with CountAttempt", context.Background(), RetryConfig{MaxAttempts: 3, CountAttempt: neverCounted}, false},
+		{"elapsed time with CountAttempt", context.Background(), RetryConfig{MaxAttempts: 3, MaxElapsedTime: time.Second, CountAttempt: neverCounted}, true},
+		{"context deadline with CountAttempt", expiring, RetryConfig{CountAttempt: neverCounted}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.RequireBound = true
+			config.Clock = newFakeClock()
+			config.Jitter = noJitter
+			op, calls := failing(errTest)
+			_, err := WithRetry(tt.ctx, config, "op", op)
+			if got := !errors.Is(err, ErrUnbounded); got != tt.want {
+				t.Fatalf("bounded = %v, want %v (error: %v)", got, tt.want, err)
+			}
+			if !tt.want && *calls != 0 {
+				t.Fatalf("calls = %d, want 0 for an unbounded config", *calls)
+			}
+		})
+	}
+}
+
+func TestMaxAttemptsForSeparateCounters(t *testing.T) {
+	errRateLimited := &HTTPError{StatusCode: 429, Message: "Too Many Requests"}
+	config := RetryConfig{
+		Clock:       newFakeClock(),
+		Jitter:      noJitter,
+		MaxAttempts: 3,
+		MaxAttemptsFor: func(err error) int {
+			var httpErr *HTTPError
+			if errors.As(err, &httpErr) && httpErr.StatusCode == 429 {
+				return 5
+			}
+			return 0
+		},
+	}
+
+	tests := []struct {
+		name      string
+		errs      func(call int) error
+		wantCalls int
+	}{
+		// Неудачи 429 не расходуют MaxAttempts: третья неудача 503 — на шестой попытке
+		{"alternating", func(call int) error {
+			if call%2 == 1 {
+				return errRateLimited
+			}
+			return errRetriable
+		}, 6},
+		{"only 429", func(int) error { return errRateLimited }, 5},
+		{"only 503", func(int) error { return errRetriable }, 3},
+		// Пять неудач 429 подряд исчерпывают свой класс, хотя 503 было лишь две
+		{"429 after 503", func(call int) error {
+			if call <= 2 {
+				return errRetriable
+			}
+			return errRateLimited
+		}, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			_, err := WithRetry(context.Background(), config, "op", func(context.Context) (int, error) {
+				calls++
+				return 0, tt.errs(calls)
+			})
+			requireReason(t, err, AttemptsExhausted)
+			if calls != tt.wantCalls {
+				t.Fatalf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// cancelKey — ключ контекста попытки с функцией отмены контекста вызывающего
+type cancelKey struct{}
+
+func TestSlowAttemptKeptWhenRetryAborted(t *testing.T) {
+	// Первая попытка успешна, но медленна; её повтор прерывается до второй попытки
+	tests := []struct {
+		name  string
+		abort func(config *RetryConfig, calls *int, cancel context.CancelFunc)
+	}{
+		{"canceled during sleep", func(config *RetryConfig, _ *int, cancel context.CancelFunc) {
+			config.OnRetry = func(int, int, time.Duration, error) { cancel() }
+		}},
+		{"canceled after attempt", func(config *RetryConfig, _ *int, cancel context.CancelFunc) {
+			config.ContextFn = func(ctx context.Context, _ int) context.Context {
+				return context.WithValue(ctx, cancelKey{}, cancel)
+			}
+		}},
+		{"gate", func(config *RetryConfig, calls *int, _ context.CancelFunc) {
+			config.Gate = func(context.Context) error {
+				if *calls > 0 {
+					return errTest
+				}
+				return nil
+			}
+		}},
+		{"host gate", func(config *RetryConfig, calls *int, _ context.CancelFunc) {
+			config.HostGate = func(context.Context, string) (func(), error) {
+				if *calls > 0 {
+					return nil, errTest
+				}
+				return func() {}, nil
+			}
+		}},
+		{"before attempt", func(config *RetryConfig, _ *int, _ context.CancelFunc) {
+			config.BeforeAttempt = func(ctx context.Context, attempt int) (context.Context, error) {
+				if attempt > 1 {
+					return ctx, errTest
+				}
+				return ctx, nil
+			}
+		}},
+		{"budget", func(config *RetryConfig, _ *int, _ context.CancelFunc) {
+			config.Budget = NewBudget(0, 0)
+		}},
+		{"repeated error", func(config *RetryConfig, _ *int, _ context.CancelFunc) {
+			config.GiveUpOnRepeat = 1
+		}},
+		{"time budget", func(config *RetryConfig, _ *int, _ context.CancelFunc) {
+			config.MaxElapsedTime = 2 * time.Second
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			clock := newFakeClock()
+			handler := &recordHandler{}
+			metrics := &callMetrics{}
+			events := make(chan Event, 16)
+			config := RetryConfig{
+				Clock:             clock,
+				MaxAttempts:       3,
+				RetryIfSlowerThan: time.Second,
+				Logger:            slog.New(handler),
+				Metrics:           metrics,
+				Events:            events,
+			}
+			calls := 0
+			tt.abort(&config, &calls, cancel)
+
+			result, err := WithRetry(ctx, config, "op", func(ctx context.Context) (string, error) {
+				calls++
+				clock.Advance(2 * time.Second)
+				if cancel, ok := ctx.Value(cancelKey{}).(context.CancelFunc); ok {
+					cancel()
+				}
+				return "slow", nil
+			})
+			if err != nil || result != "slow" {
+				t.Fatalf("WithRetry() = %q, %v, want the slow result without error", result, err)
+			}
+			if calls != 1 {
+				t.Fatalf("calls = %d, want 1", calls)
+			}
+
+			// Медленный успех оформляется как успех: событие, метрика и никаких логов прерывания
+			close(events)
+			var last Event
+			for event := range events {
+				last = event
+			}
+			if last.Type != EventSuccess || last.Attempt != 1 {
+				t.Fatalf("last event = %+v, want success of attempt 1", last)
+			}
+			if n := len(metrics.calls); n == 0 || metrics.calls[n-1] != "success 1" ||
+				slices.ContainsFunc(metrics.calls, func(call string) bool { return strings.HasPrefix(call, "give up") }) {
+				t.Fatalf("metrics = %v, want success of attempt 1 without give up", metrics.calls)
+			}
+			for _, record := range handler.records {
+				if strings.Contains(record.Message, "aborted") || strings.Contains(record.Message, "exhausted") {
+					t.Fatalf("unexpected log %q for a slow success", record.Message)
+				}
+			}
+		})
+	}
+}
+
+// callMetrics — Metrics, запоминающий вызовы по порядку
+type callMetrics struct {
+	calls []string
+}
+
+func (m *callMetrics) Attempt(string, time.Duration, error) { m.calls = append(m.calls, "attempt") }
+func (m *callMetrics) Retry(_ string, attempt int) {
+	m.calls = append(m.calls, fmt.Sprintf("retry %d", attempt))
+}
+func (m *callMetrics) Success(_ string, attempts int) {
+	m.calls = append(m.calls, fmt.Sprintf("success %d", attempts))
+}
+func (m *callMetrics) GiveUp(_ string, attempts int, reason Reason) {
+	m.calls = append(m.calls, fmt.Sprintf("give up %d %s", attempts, reason))
+}
+
+func TestWakeCutsSleepShort(t *testing.T) {
+	// Часы не сдвигаются: следующая попытка начинается только по сигналу Wake
+	clock := newManualClock()
+	wake := make(chan struct{})
+	done := make(chan int, 1)
+	go func() {
+		result, _ := WithRetry(context.Background(), RetryConfig{
+			Clock:    clock,
+			MinDelay: time.Hour,
+			MaxDelay: time.Hour,
+			Wake:     wake,
+		}, "op", failTimes(1, errRetriable))
+		done <- result
+	}()
+
+	if delay := <-clock.waiting; delay < 30*time.Minute {
+		t.Fatalf("delay = %s, want the hour-long backoff", delay)
+	}
+	wake <- struct{}{}
+	select {
+	case result := <-done:
+		if result != 2 {
+			t.Fatalf("result = %d, want success on attempt 2", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Wake did not end the sleep")
+	}
+	if elapsed := clock.Now().Sub(testEpoch); elapsed != 0 {
+		t.Fatalf("clock advanced by %s, want the retry without waiting out the delay", elapsed)
+	}
+}
+
+func TestNilOperation(t *testing.T) {
+	ctx := context.Background()
+	op, _ := failing(errTest)
+	var nilOp func(context.Context) (int, error)
+
+	checks := map[string]error{}
+	_, checks["WithRetry"] = WithRetry(ctx, RetryConfig{}, "op", nilOp)
+	_, checks["WithRetrySteps"] = WithRetrySteps(ctx, RetryConfig{}, "op", op, nilOp)
+	_, checks["Poll operation"] = Poll(ctx, RetryConfig{}, "op", nilOp, func(int) bool { return true })
+	_, checks["Poll done"] = Poll(ctx, RetryConfig{}, "op", op, nil)
+	_, checks["Hedge"] = Hedge(ctx, HedgeConfig{}, "op", nilOp)
+	_, checks["Map"] = Map(ctx, MapConfig{}, "op", []int{1}, (func(context.Context, int) (int, error))(nil))
+	checks["Forever"] = Forever(ctx, ForeverConfig{}, "op", nil)
+	checks["Step"] = Step(ctx, RetryConfig{}, "op", 1, nilOp).Err
+
+	for name, err := range checks {
+		if !errors.Is(err, ErrNilOperation) {
+			t.Fatalf("%s error = %v, want ErrNilOperation", name, err)
+		}
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	const attemptDuration = 2 * time.Second
+	tests := []struct {
+		name       string
+		deadline   time.Duration // От testEpoch (0 = не задан)
+		maxElapsed time.Duration
+		ctxTimeout time.Duration
+		budget     time.Duration // Ближайшая граница бюджета от начала
+	}{
+		{"deadline only", 10 * time.Second, 0, 0, 10 * time.Second},
+		{"deadline tighter than MaxElapsedTime", 10 * time.Second, time.Minute, 0, 10 * time.Second},
+		{"MaxElapsedTime tighter than deadline", time.Minute, 10 * time.Second, 0, 10 * time.Second},
+		{"context tighter than deadline", time.Minute, 0, 10 * time.Second, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.ctxTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctxTimeout)
+				defer cancel()
+			}
+			clock := newFakeClock()
+			config := RetryConfig{
+				Clock:          clock,
+				MaxAttempts:    100,
+				MinDelay:       time.Second,
+				MaxDelay:       time.Second,
+				Jitter:         noJitter,
+				MaxElapsedTime: tt.maxElapsed,
+			}
+			if tt.deadline > 0 {
+				config.Deadline = testEpoch.Add(tt.deadline)
+			}
+			_, err := WithRetry(ctx, config, "op", func(context.Context) (int, error) {
+				clock.Advance(attemptDuration)
+				return 0, errRetriable
+			})
+
+			// Последняя попытка начата не позже границы за вычетом запаса MinRemainingBudget (MinDelay),
+			// а ещё один цикл ожидания и попытки в бюджет уже не помещался
+			retryErr := requireReason(t, err, TimeBudgetExceeded)
+			lastStart := retryErr.Elapsed - attemptDuration
+			latest := tt.budget - config.MinDelay
+			if lastStart > latest || lastStart <= latest-attemptDuration-config.MinDelay {
+				t.Fatalf("last attempt started at %s, want within %s of %s", lastStart, attemptDuration+config.MinDelay, latest)
+			}
+		})
+	}
+}
+
+func TestDeadlineInPast(t *testing.T) {
+	// Прошедший (или наступивший ровно сейчас) Deadline не допускает даже первой попытки
+	for _, deadline := range []time.Time{testEpoch.Add(-time.Second), testEpoch} {
+		op, calls := failing(errRetriable)
+		_, err := WithRetry(context.Background(), RetryConfig{
+			Clock:    newFakeClock(),
+			Deadline: deadline,
+		}, "op", op)
+		retryErr := requireReason(t, err, TimeBudgetExceeded)
+		if *calls != 0 || retryErr.Attempts != 0 || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("calls = %d, Attempts = %d, error = %v, want no attempt and context.DeadlineExceeded",
+				*calls, retryErr.Attempts, err)
+		}
+	}
+}
+
+func TestStopRetryWithResult(t *testing.T) {
+	errQuota := errors.New("quota exhausted")
+	tests := []struct {
+		name    string
+		stop    func(partial []string) ([]string, error)
+		wantErr error
+	}{
+		{"with error", func(p []string) ([]string, error) { return StopRetry(p, errQuota) }, errQuota},
+		{"without error", func(p []string) ([]string, error) { return StopRetry(p, nil) }, ErrStop},
+		{"wrapped ErrStop", func(p []string) ([]string, error) { return p, fmt.Errorf("give up: %w", ErrStop) }, ErrStop},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make(chan Event, 16)
+			calls := 0
+			result, err := WithRetry(context.Background(), RetryConfig{
+				Clock:       newFakeClock(),
+				MaxAttempts: 5,
+				Events:      events,
+			}, "op", func(context.Context) ([]string, error) {
+				calls++
+				if calls == 2 {
+					return tt.stop([]string{"partial"})
+				}
+				return nil, errRetriable
+			})
+
+			// Остановка возвращает результат и ошибку попытки как есть, без RetryError
+			if calls != 2 {
+				t.Fatalf("calls = %d, want retries to stop at the second attempt", calls)
+			}
+			if len(result) != 1 || result[0] != "partial" {
+				t.Fatalf("result = %v, want the result passed to StopRetry", result)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			var retryErr *RetryError
+			if errors.As(err, &retryErr) {
+				t.Fatalf("error = %v, want the operation error, not *RetryError", err)
+			}
+
+			// Остановка не считается успехом
+			close(events)
+			for event := range events {
+				if event.Type == EventSuccess {
+					t.Fatalf("got %s event for a stopped operation", event.Type)
+				}
+			}
+		})
+	}
+}
+
+func TestGiveUpOnRepeatErrorsEqual(t *testing.T) {
+	// Ошибки отличаются только отметкой времени в тексте
+	timestamped := func(statuses ...int) (func(context.Context) (int, error), *int) {
+		calls := 0
+		return func(context.Context) (int, error) {
+			status := statuses[min(calls, len(statuses)-1)]
+			calls++
+			return 0, &HTTPError{StatusCode: status, Message: fmt.Sprintf("unavailable at 12:00:%02d", calls)}
+		}, &calls
+	}
+	sameStatus := func(a, b error) bool {
+		var errA, errB *HTTPError
+		return errors.As(a, &errA) && errors.As(b, &errB) && errA.StatusCode == errB.StatusCode
+	}
+
+	tests := []struct {
+		name      string
+		equal     func(a, b error) bool
+		statuses  []int
+		reason    Reason
+		wantCalls int
+	}{
+		{"errors.Is sees different errors", nil, []int{503}, AttemptsExhausted, 6},
+		{"comparator sees the same error", sameStatus, []int{503}, RepeatedError, 3},
+		{"different status resets the count", sameStatus, []int{503, 503, 502, 502, 502}, RepeatedError, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, calls := timestamped(tt.statuses...)
+			_, err := WithRetry(context.Background(), RetryConfig{
+				Clock:          newFakeClock(),
+				MaxAttempts:    6,
+				GiveUpOnRepeat: 3,
+				ErrorsEqual:    tt.equal,
+			}, "op", op)
+			requireReason(t, err, tt.reason)
+			if *calls != tt.wantCalls {
+				t.Fatalf("calls = %d, want %d", *calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWouldRetry(t *testing.T) {
+	errCustom := errors.New("custom retriable")
+	custom := func(err error) bool { return errors.Is(err, errCustom) }
+	badRequest := &HTTPError{StatusCode: 400}
+
+	tests := []struct {
+		name   string
+		config RetryConfig
+		err    error
+		want   bool
+	}{
+		{"nil error", RetryConfig{}, nil, false},
+		{"default, 503", RetryConfig{}, errRetriable, true},
+		{"default, 400", RetryConfig{}, badRequest, false},
+		{"default, plain error", RetryConfig{}, errTest, false},
+		{"custom retries its error", RetryConfig{ShouldRetry: custom}, errCustom, true},
+		{"custom replaces default", RetryConfig{ShouldRetry: custom}, errRetriable, false},
+		{"Classify wins over ShouldRetry", RetryConfig{
+			ShouldRetry: custom,
+			Classify:    func(err error) (bool, float64) { return errors.Is(err, errTest), 2 },
+		}, errTest, true},
+		{"ExtraRetriableStatus", RetryConfig{ExtraRetriableStatus: []int{400}}, badRequest, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.WouldRetry(tt.err); got != tt.want {
+				t.Fatalf("WouldRetry(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+			if tt.err == nil {
+				return
+			}
+
+			// Решение совпадает с поведением WithRetry
+			config := tt.config
+			config.Clock = newFakeClock()
+			config.MaxAttempts = 2
+			op, calls := failing(tt.err)
+			_, _ = WithRetry(context.Background(), config, "op", op)
+			if retried := *calls > 1; retried != tt.want {
+				t.Fatalf("WithRetry() made %d calls, WouldRetry() = %t", *calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestPersistState(t *testing.T) {
+	clock := newFakeClock()
+	var states []RetryState
+	calls := 0
+	_, err := WithRetry(context.Background(), RetryConfig{
+		Clock:       clock,
+		MaxAttempts: 4,
+		MinDelay:    time.Second,
+		MaxDelay:    time.Minute,
+		Jitter:      noJitter,
+		PersistState: func(state RetryState) error {
+			states = append(states, state)
+			return nil
+		},
+	}, "sync", func(context.Context) (int, error) {
+		calls++
+		return 0, &HTTPError{StatusCode: 503, Message: fmt.Sprintf("attempt %d", calls)}
+	})
+	requireReason(t, err, AttemptsExhausted)
+
+	// Состояние сохраняется после каждого запланированного повтора, но не после последней попытки
+	sleeps := clock.Sleeps()
+	if len(states) != 3 || len(sleeps) != 3 {
+		t.Fatalf("states = %+v, sleeps = %v, want 3 of each", states, sleeps)
+	}
+	for i, state := range states {
+		var httpErr *HTTPError
+		if state.Operation != "sync" || state.NextAttempt != i+2 || state.Delay != sleeps[i] ||
+			!errors.As(state.LastError, &httpErr) || httpErr.Message != fmt.Sprintf("attempt %d", i+1) {
+			t.Fatalf("state %d = %+v, want next attempt %d, delay %s and the error of attempt %d",
+				i+1, state, i+2, sleeps[i], i+1)
+		}
+	}
+
+	// Возобновление с сохранённого NextAttempt продолжает нумерацию
+	var resumed []RetryState
+	_, _ = WithRetry(context.Background(), RetryConfig{
+		Clock:        newFakeClock(),
+		MaxAttempts:  6,
+		StartAttempt: states[2].NextAttempt,
+		PersistState: func(state RetryState) error {
+			resumed = append(resumed, state)
+			return nil
+		},
+	}, "sync", failTimes(1, errRetriable))
+	if len(resumed) != 1 || resumed[0].NextAttempt != states[2].NextAttempt+1 {
+		t.Fatalf("resumed states = %+v, want one with next attempt %d", resumed, states[2].NextAttempt+1)
+	}
+}
+
+func TestPersistStateErrorContinues(t *testing.T) {
+	handler := &recordHandler{}
+	persisted := 0
+	result, err := WithRetry(context.Background(), RetryConfig{
+		Clock:       newFakeClock(),
+		MaxAttempts: 3,
+		Logger:      slog.New(handler),
+		PersistState: func(RetryState) error {
+			persisted++
+			return errors.New("disk full")
+		},
+	}, "op", failTimes(2, errRetriable))
+
+	if err != nil || result != 3 || persisted != 2 {
+		t.Fatalf("WithRetry() = %d, %v after %d PersistState calls, want success on attempt 3 after 2", result, err, persisted)
+	}
+	warnings := 0
+	for _, record := range handler.records {
+		if record.Message == "Failed to persist retry state" {
+			warnings++
+		}
+	}
+	if warnings != 2 {
+		t.Fatalf("logged %d persist failures, want 2", warnings)
+	}
+}
+
+func TestClassifyMultiplier(t *testing.T) {
+	clock := newFakeClock()
+	statuses := []int{429, 503, 429}
+	calls := 0
+	_, err := WithRetry(context.Background(), RetryConfig{
+		Clock:       clock,
+		MaxAttempts: len(statuses) + 1,
+		MinDelay:    time.Second,
+		MaxDelay:    time.Minute,
+		Jitter:      noJitter,
+		Classify: func(err error) (bool, float64) {
+			var httpErr *HTTPError
+			if errors.As(err, &httpErr) && httpErr.StatusCode == 429 {
+				return true, 3
+			}
+			return true, 1
+		},
+	}, "op", func(context.Context) (int, error) {
+		calls++
+		return 0, &HTTPError{StatusCode: statuses[min(calls, len(statuses))-1]}
+	})
+	requireReason(t, err, AttemptsExhausted)
+
+	// Задержки стратегии 1s, 2s, 4s: после 429 они втрое длиннее, после 503 — без изменений
+	want := []time.Duration{3 * time.Second, 2 * time.Second, 12 * time.Second}
+	if sleeps := clock.Sleeps(); !slices.Equal(sleeps, want) {
+		t.Fatalf("sleeps = %v, want %v", sleeps, want)
+	}
+}
+
+func TestMaxElapsedTimeBoundsSleepManySeeds(t *testing.T) {
+	const budget = 10 * time.Second
+	for seed := range uint64(500) {
+		// Длительность попытки тоже зависит от сида: от 10ms до 1s
+		attemptDuration := time.Duration(seed%100+1) * 10 * time.Millisecond
+		clock := newFakeClock()
+		_, err := WithRetry(context.Background(), RetryConfig{
+			Clock:          clock,
+			MaxAttempts:    1000,
+			MinDelay:       200 * time.Millisecond,
+			MaxDelay:       3 * time.Second,
+			Jitter:         DeterministicJitter(seed),
+			MaxElapsedTime: budget,
+		}, "op", func(context.Context) (int, error) {
+			clock.Advance(attemptDuration)
+			return 0, errRetriable
+		})
+		requireReason(t, err, TimeBudgetExceeded)
+
+		var slept time.Duration
+		for _, delay := range clock.Sleeps() {
+			slept += delay
+		}
+		if slept > budget {
+			t.Fatalf("seed %d: slept %s in total, want at most MaxElapsedTime %s", seed, slept, budget)
+		}
+	}
+}
+
+func TestReportFirstError(t *testing.T) {
+	errs := []error{
+		&HTTPError{StatusCode: 503, Message: "first"},
+		&HTTPError{StatusCode: 502, Message: "second"},
+		&HTTPError{StatusCode: 504, Message: "last"},
+	}
+	for _, reportFirst := range []bool{false, true} {
+		calls := 0
+		_, err := WithRetry(context.Background(), RetryConfig{
+			Clock:            newFakeClock(),
+			MaxAttempts:      len(errs),
+			ReportFirstError: reportFirst,
+		}, "op", func(context.Context) (int, error) {
+			calls++
+			return 0, errs[calls-1]
+		})
+		retryErr := requireReason(t, err, AttemptsExhausted)
+
+		want := errs[len(errs)-1]
+		if reportFirst {
+			want = errs[0]
+		}
+		if retryErr.LastError != want || !errors.Is(err, want) {
+			t.Fatalf("ReportFirstError %t: LastError = %v, want %v", reportFirst, retryErr.LastError, want)
+		}
+		if retryErr.FirstError != errs[0] {
+			t.Fatalf("ReportFirstError %t: FirstError = %v, want %v", reportFirst, retryErr.FirstError, errs[0])
+		}
+	}
+}
+
+func TestStartAttemptDelays(t *testing.T) {
+	run := func(startAttempt int) ([]time.Duration, int) {
+		clock := newFakeClock()
+		op, calls := failing(errRetriable)
+		_, err := WithRetry(context.Background(), RetryConfig{
+			Clock:        clock,
+			MaxAttempts:  6,
+			MinDelay:     time.Second,
+			MaxDelay:     time.Minute,
+			Jitter:       DeterministicJitter(3),
+			StartAttempt: startAttempt,
+		}, "op", op)
+		requireReason(t, err, AttemptsExhausted)
+		return clock.Sleeps(), *calls
+	}
+
+	full, _ := run(1)
+	resumed, calls := run(4)
+	// Возобновлённая операция выполняет попытки 4-6 с теми же задержками, что и полный прогон
+	if calls != 3 || !slices.Equal(resumed, full[3:]) {
+		t.Fatalf("StartAttempt 4: %d calls with sleeps %v, want 3 calls with %v", calls, resumed, full[3:])
+	}
+	if want := 8 * time.Second; resumed[0] < want/2 || resumed[0] >= want*3/2 {
+		t.Fatalf("first delay = %s, want the attempt-4 delay around %s", resumed[0], want)
+	}
+}
+
+func TestGate(t *testing.T) {
+	t.Run("blocks until open", func(t *testing.T) {
+		open := make(chan struct{})
+		waiting := make(chan struct{}, 1)
+		var calls atomic.Int32
+		done := make(chan error, 1)
+		go func() {
+			_, err := WithRetry(context.Background(), RetryConfig{
+				Clock: newFakeClock(),
+				Gate: func(ctx context.Context) error {
+					waiting <- struct{}{}
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case <-open:
+						return nil
+					}
+				},
+			}, "op", func(context.Context) (int, error) {
+				calls.Add(1)
+				return 1, nil
+			})
+			done <- err
+		}()
+
+		<-waiting
+		if calls.Load() != 0 {
+			t.Fatal("operation ran while the gate was closed")
+		}
+		close(open)
+		if err := <-done; err != nil || calls.Load() != 1 {
+			t.Fatalf("WithRetry() error = %v after %d calls, want success after the gate opened", err, calls.Load())
+		}
+	})
+
+	t.Run("canceled while blocked", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		waiting := make(chan struct{}, 1)
+		op, calls := failing(errRetriable)
+		done := make(chan error, 1)
+		go func() {
+			_, err := WithRetry(ctx, RetryConfig{
+				Clock: newFakeClock(),
+				Gate: func(ctx context.Context) error {
+					waiting <- struct{}{}
+					<-ctx.Done()
+					return ctx.Err()
+				},
+			}, "op", op)
+			done <- err
+		}()
+
+		<-waiting
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) || *calls != 0 {
+			t.Fatalf("WithRetry() error = %v after %d calls, want context.Canceled without attempts", err, *calls)
+		}
+	})
+}
+
+func TestSuccessErrors(t *testing.T) {
+	errAlreadyExists := errors.New("already exists")
+	config := RetryConfig{Clock: newFakeClock(), MaxAttempts: 5, SuccessErrors: []error{errAlreadyExists}}
+
+	// Обёрнутая «успешная» ошибка возвращается как успех с результатом попытки, без повторов
+	calls := 0
+	got, err := WithRetry(context.Background(), config, "create", func(context.Context) (int, error) {
+		calls++
+		if calls == 1 {
+			return 0, errRetriable
+		}
+		return 7, fmt.Errorf("create item: %w", errAlreadyExists)
+	})
+	if err != nil || got != 7 || calls != 2 {
+		t.Fatalf("WithRetry() = %d, %v after %d calls, want 7, nil after 2", got, err, calls)
+	}
+
+	// Прочие неповторяемые ошибки по-прежнему прерывают цикл
+	op, failed := failing(fmt.Errorf("create item: %w", errTest))
+	_, err = WithRetry(context.Background(), config, "create", op)
+	requireReason(t, err, NonRetriable)
+	if *failed != 1 {
+		t.Fatalf("calls = %d, want 1", *failed)
+	}
+}
+
+// contextFnKey — ключ контекста попытки, который добавляет ContextFn в тесте шагов
+type contextFnKey struct{}
+
+func TestWithRetryStepsByAttempt(t *testing.T) {
+	tests := []struct {
+		name         string
+		startAttempt int
+		want         []int // Номера шагов по попыткам
+	}{
+		{"from first attempt", 1, []int{0, 1, 2, 2, 2}},
+		{"resumed", 2, []int{1, 2, 2, 2}},
+		{"resumed past steps", 4, []int{2, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ran []int
+			step := func(i int) func(context.Context) (int, error) {
+				return func(ctx context.Context) (int, error) {
+					if ctx.Value(contextFnKey{}) != "set" {
+						t.Fatalf("step %d: attempt context lost the ContextFn value", i)
+					}
+					ran = append(ran, i)
+					return 0, errRetriable
+				}
+			}
+			_, err := WithRetrySteps(context.Background(), RetryConfig{
+				Clock:        newFakeClock(),
+				MaxAttempts:  5,
+				StartAttempt: tt.startAttempt,
+				ContextFn: func(ctx context.Context, _ int) context.Context {
+					return context.WithValue(ctx, contextFnKey{}, "set")
+				},
+			}, "op", step(0), step(1), step(2))
+
+			requireReason(t, err, AttemptsExhausted)
+			if !slices.Equal(ran, tt.want) {
+				t.Fatalf("steps = %v, want %v", ran, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordDelays(t *testing.T) {
+	run := func(record bool) (*RetryError, []time.Duration) {
+		clock := newFakeClock()
+		op, _ := failing(errRetriable)
+		_, err := WithRetry(context.Background(), RetryConfig{
+			Clock:        clock,
+			MaxAttempts:  5,
+			MinDelay:     time.Second,
+			MaxDelay:     10 * time.Second,
+			RecordDelays: record,
+		}, "op", op)
+		return requireReason(t, err, AttemptsExhausted), clock.Sleeps()
+	}
+
+	// Записанные задержки с jitter совпадают с ожиданиями, которые видели часы
+	retryErr, sleeps := run(true)
+	if len(sleeps) != 4 || !slices.Equal(retryErr.Delays, sleeps) {
+		t.Fatalf("Delays = %v, clock sleeps = %v, want the same 4 delays", retryErr.Delays, sleeps)
+	}
+
+	if retryErr, _ := run(false); retryErr.Delays != nil {
+		t.Fatalf("Delays = %v without RecordDelays, want nil", retryErr.Delays)
+	}
+}
+
+func TestExtraRetriableStatusConflict(t *testing.T) {
+	conflict := &HTTPError{StatusCode: 409, Message: "Conflict"}
+	tests := []struct {
+		name      string
+		extra     []int
+		wantErr   bool
+		wantCalls int
+	}{
+		{"not listed", nil, true, 1},
+		{"other status listed", []int{423}, true, 1},
+		{"listed", []int{423, 409}, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			_, err := WithRetry(context.Background(), RetryConfig{
+				Clock:                newFakeClock(),
+				MaxAttempts:          3,
+				ExtraRetriableStatus: tt.extra,
+			}, "op", func(context.Context) (int, error) {
+				calls++
+				if calls == 1 {
+					return 0, conflict
+				}
+				return calls, nil
+			})
+			if (err != nil) != tt.wantErr || calls != tt.wantCalls {
+				t.Fatalf("WithRetry() error = %v after %d calls, want error %v after %d", err, calls, tt.wantErr, tt.wantCalls)
+			}
+			if tt.wantErr {
+				requireReason(t, err, NonRetriable)
+			}
+		})
+	}
+}
+
+// idempotencyKey — ключ контекста с ключом идемпотентности попытки
+type idempotencyKey struct{}
+
+func TestBeforeAttemptRefreshOnRetry(t *testing.T) {
+	// Первая попытка использует исходный ключ, повторы получают новый ключ от BeforeAttempt
+	var keys []string
+	ctx := context.WithValue(context.Background(), idempotencyKey{}, "initial")
+	got, err := WithRetry(ctx, RetryConfig{
+		Clock:       newFakeClock(),
+		MaxAttempts: 5,
+		BeforeAttempt: func(ctx context.Context, attempt int) (context.Context, error) {
+			if attempt == 1 {
+				return ctx, nil
+			}
+			return context.WithValue(ctx, idempotencyKey{}, fmt.Sprintf("key-%d", attempt)), nil
+		},
+	}, "op", func(ctx context.Context) (int, error) {
+		keys = append(keys, ctx.Value(idempotencyKey{}).(string))
+		if len(keys) < 3 {
+			return 0, errRetriable
+		}
+		return len(keys), nil
+	})
+
+	if err != nil || got != 3 {
+		t.Fatalf("WithRetry() = %d, %v, want 3, nil", got, err)
+	}
+	if want := []string{"initial", "key-2", "key-3"}; !slices.Equal(keys, want) {
+		t.Fatalf("idempotency keys = %v, want %v", keys, want)
+	}
+}
+
+func TestBeforeAttemptSetupFailure(t *testing.T) {
+	// Ошибка подготовки неповторяемая: цикл прерывается без вызова операции
+	errRefresh := errors.New("token refresh failed")
+	op, calls := failing(errRetriable)
+	_, err := WithRetry(context.Background(), RetryConfig{
+		Clock:       newFakeClock(),
+		MaxAttempts: 5,
+		BeforeAttempt: func(ctx context.Context, attempt int) (context.Context, error) {
+			if attempt == 2 {
+				return ctx, errRefresh
+			}
+			return ctx, nil
+		},
+	}, "op", op)
+
+	retryErr := requireReason(t, err, NonRetriable)
+	if !errors.Is(err, errRefresh) || retryErr.Attempts != 2 || *calls != 1 {
+		t.Fatalf("error = %v, Attempts = %d, calls = %d, want the refresh error at attempt 2 after 1 call",
+			err, retryErr.Attempts, *calls)
+	}
+}
+
+// retryCall — аргументы одного вызова OnRetry
+type retryCall struct {
+	attempt, maxAttempts int
+	delay                time.Duration
+	err                  error
+	slept                int // Ожиданий перед вызовом
+}
+
+func TestOnRetryArguments(t *testing.T) {
+	attemptErr := func(attempt int) error { return fmt.Errorf("attempt %d: %w", attempt, errRetriable) }
+
+	t.Run("WithRetry", func(t *testing.T) {
+		clock := newFakeClock()
+		var calls []retryCall
+		attempt := 0
+		_, err := WithRetry(context.Background(), RetryConfig{
+			Clock:       clock,
+			MaxAttempts: 4,
+			MinDelay:    time.Second,
+			MaxDelay:    time.Minute,
+			Jitter:      noJitter,
+			OnRetry: func(attempt, maxAttempts int, delay time.Duration, err error) {
+				calls = append(calls, retryCall{attempt, maxAttempts, delay, err, len(clock.Sleeps())})
+			},
+		}, "op", func(context.Context) (int, error) {
+			attempt++
+			return 0, attemptErr(attempt)
+		})
+		requireReason(t, err, AttemptsExhausted)
+
+		// OnRetry вызывается перед каждым ожиданием, но не после последней попытки
+		want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+		if len(calls) != len(want) {
+			t.Fatalf("OnRetry called %d times, want %d", len(calls), len(want))
+		}
+		for i, call := range calls {
+			if call.attempt != i+1 || call.maxAttempts != 4 || call.delay != want[i] ||
+				call.err.Error() != attemptErr(i+1).Error() || call.slept != i {
+				t.Fatalf("OnRetry call %d = %+v, want attempt %d of 4, delay %s, error %q before sleep %d",
+					i, call, i+1, want[i], attemptErr(i+1), i+1)
+			}
+		}
+		if sleeps := clock.Sleeps(); !slices.Equal(sleeps, want) {
+			t.Fatalf("sleeps = %v, want %v", sleeps, want)
+		}
+	})
+
+	t.Run("Forever", func(t *testing.T) {
+		// Для бесконечных повторов maxAttempts = -1, attempt — номер неудачи подряд
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var calls []retryCall
+		run := 0
+		err := Forever(ctx, ForeverConfig{RetryConfig: RetryConfig{
+			Clock:    newFakeClock(),
+			MinDelay: time.Second,
+			MaxDelay: time.Minute,
+			Jitter:   noJitter,
+			OnRetry: func(attempt, maxAttempts int, delay time.Duration, err error) {
+				calls = append(calls, retryCall{attempt: attempt, maxAttempts: maxAttempts, delay: delay, err: err})
+			},
+		}}, "op", func(context.Context) error {
+			run++
+			if run > 3 {
+				cancel()
+				return nil
+			}
+			return attemptErr(run)
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Forever() error = %v, want context.Canceled", err)
+		}
+
+		want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+		if len(calls) != len(want) {
+			t.Fatalf("OnRetry called %d times, want %d", len(calls), len(want))
+		}
+		for i, call := range calls {
+			if call.attempt != i+1 || call.maxAttempts != -1 || call.delay != want[i] || call.err.Error() != attemptErr(i+1).Error() {
+				t.Fatalf("OnRetry call %d = %+v, want attempt %d of -1, delay %s, error %q", i, call, i+1, want[i], attemptErr(i+1))
+			}
+		}
+	})
+}
@@ -0,0 +1,213 @@
+package retryhttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/alfzs/retry"
+)
+
+// Transport реализует http.RoundTripper с повторными попытками.
+// Повторяются сетевые ошибки и ответы с повторяемыми статусами (5xx, 429 и ExtraRetriableStatus).
+// Только такие ответы становятся ошибками попыток, поэтому Config.ShouldRetry (например,
+// retry.RetryOnHeader) может лишь отказаться от их повтора; другие ответы, например 4xx
+// с заголовком X-Should-Retry, передаются классификатору через ClassifyResponse.
+// Запросы с телом без GetBody выполняются один раз, так как тело нельзя перечитать.
+// Config.OperationTimeout, как http.Client.Timeout, ограничивает и чтение тела ответа:
+// контекст операции отменяется при закрытии тела.
+// Операция называется методом и хостом запроса ("GET api.example.com"), полный URL пишется
+// в логи полем url.
+type Transport struct {
+	Config retry.RetryConfig // Параметры повторных попыток
+	Base   http.RoundTripper // Базовый транспорт (nil = http.DefaultTransport)
+
+	// ClassifyResponse проверяет ответ до классификации по статусу (например, ошибку в теле
+	// при статусе 200). Непустая ошибка становится ошибкой попытки и классифицируется
+	// Config; nil оставляет проверку статуса. Если функция читает тело, она должна
+	// восстановить resp.Body, так как последний ответ возвращается вызывающему.
+	ClassifyResponse func(*http.Response) error
+
+	// RetryCountHeader — имя заголовка (например, X-Retry-Count), в который записывается число
+	// повторов, выполненных для возвращённого ответа (пусто = заголовок не добавляется).
+	// Без заголовка число повторов доступно через Retries.
+	RetryCountHeader string
+}
+
+// attemptKey — ключ контекста запроса попытки с номером попытки
+type attemptKey struct{}
+
+// Retries возвращает число повторов, выполненных Transport до получения ответа resp
+// (0 для ответа первой попытки и ответов других транспортов)
+func Retries(resp *http.Response) int {
+	if resp == nil || resp.Request == nil {
+		return 0
+	}
+	attempt, _ := resp.Request.Context().Value(attemptKey{}).(int)
+	return max(attempt-1, 0)
+}
+
+// New создаёт Transport поверх базового транспорта
+func New(config retry.RetryConfig, base http.RoundTripper) *Transport {
+	return &Transport{Config: config, Base: base}
+}
+
+// RoundTrip выполняет запрос с повторными попытками.
+// Если все попытки завершились повторяемым статусом, возвращается последний ответ без ошибки.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// Последний ответ нужен, чтобы отдать его при исчерпании попыток на статусе
+	config := t.Config
+	config.ZeroResultOnError = false
+
+	// WithRetry отменяет контекст OperationTimeout при возврате, а тело ответа читается позже,
+	// поэтому Transport выводит этот контекст сам и отменяет его при закрытии тела
+	ctx := req.Context()
+	var cancel context.CancelFunc
+	if config.OperationTimeout > 0 {
+		ctx, cancel = context.WithTimeoutCause(ctx, config.OperationTimeout, retry.ErrOperationTimeout)
+		config.OperationTimeout = 0
+		req = req.WithContext(ctx)
+	}
+
+	// Тело без GetBody нельзя перечитать: повтор отправил бы обрезанные данные
+	if !canReplay(req) {
+		resp, err := base.RoundTrip(req)
+		if err != nil {
+			cancelOperation(cancel)
+			return resp, err
+		}
+		return t.withRetryCount(bindCancel(resp, cancel)), nil
+	}
+
+	var last *http.Response
+	attempt := 0
+	// Имя операции — метод и хост: путь и query сделали бы метки Metrics неограниченными.
+	// Полный URL попадает только в логи.
+	fields := config.LogFields
+	config.LogFields = func() []slog.Attr {
+		attrs := []slog.Attr{slog.String("url", req.URL.Redacted())}
+		if fields != nil {
+			attrs = append(fields(), attrs...)
+		}
+		return attrs
+	}
+
+	resp, err := retry.WithRetry(ctx, config, req.Method+" "+req.URL.Host,
+		func(ctx context.Context) (*http.Response, error) {
+			// Ответ предыдущей попытки больше не нужен
+			if last != nil {
+				closeBody(last)
+				last = nil
+			}
+
+			attempt++
+
+			// Первая попытка использует исходное тело, следующие получают свежее через GetBody
+			attemptReq := req.Clone(context.WithValue(ctx, attemptKey{}, attempt))
+			if attempt > 1 && req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+
+			resp, err := base.RoundTrip(attemptReq)
+			if err != nil {
+				return nil, err
+			}
+
+			if t.ClassifyResponse != nil {
+				if err := t.ClassifyResponse(resp); err != nil {
+					last = resp
+					return resp, err
+				}
+			}
+
+			httpErr := retry.NewHTTPError(resp)
+			if httpErr.Temporary() || slices.Contains(t.Config.ExtraRetriableStatus, resp.StatusCode) {
+				last = resp
+				return resp, httpErr
+			}
+			return resp, nil
+		})
+
+	if err == nil {
+		return t.withRetryCount(bindCancel(resp, cancel)), nil
+	}
+
+	// Попытки исчерпаны на статусе ответа — отдаём сам ответ, как обычный RoundTripper
+	var httpErr *retry.HTTPError
+	if resp != nil && ctx.Err() == nil && errors.As(err, &httpErr) {
+		return t.withRetryCount(bindCancel(resp, cancel)), nil
+	}
+
+	if resp != nil {
+		closeBody(resp)
+	}
+	cancelOperation(cancel)
+	return nil, err
+}
+
+// cancelOnClose отменяет контекст OperationTimeout при закрытии тела ответа
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// bindCancel привязывает отмену контекста OperationTimeout (если он создан) к закрытию тела ответа
+func bindCancel(resp *http.Response, cancel context.CancelFunc) *http.Response {
+	if cancel == nil {
+		return resp
+	}
+	if resp.Body == nil {
+		cancel()
+		return resp
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp
+}
+
+// cancelOperation отменяет контекст OperationTimeout, если он создан
+func cancelOperation(cancel context.CancelFunc) {
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// withRetryCount записывает число повторов в заголовок RetryCountHeader, если он задан
+func (t *Transport) withRetryCount(resp *http.Response) *http.Response {
+	if t.RetryCountHeader != "" && resp != nil {
+		if resp.Header == nil {
+			resp.Header = make(http.Header)
+		}
+		resp.Header.Set(t.RetryCountHeader, strconv.Itoa(Retries(resp)))
+	}
+	return resp
+}
+
+// canReplay сообщает, можно ли безопасно отправить тело запроса повторно
+func canReplay(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// closeBody вычитывает и закрывает тело ответа, чтобы соединение вернулось в пул
+func closeBody(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
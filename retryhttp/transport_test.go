@@ -0,0 +1,513 @@
+package retryhttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alfzs/retry"
+)
+
+// flakyServer отвечает 503 на первые failures запросов, затем 200 с телом "ok"
+func flakyServer(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+// recordingServer отвечает 503 на первые failures запросов, затем 200 и сохраняет тела запросов
+func recordingServer(t *testing.T, failures int) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		n := len(bodies)
+		mu.Unlock()
+		if n <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(bodies)
+	}
+}
+
+// sleepClock — retry.Clock, в котором ожидание проходит мгновенно; задержки сохраняются в sleeps
+type sleepClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *sleepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *sleepClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.sleeps = append(c.sleeps, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestTransportRetriesUnavailable(t *testing.T) {
+	srv, requests := flakyServer(t, 2)
+	client := &http.Client{Transport: New(retry.RetryConfig{MaxAttempts: 3, Clock: &sleepClock{}}, nil)}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" || requests.Load() != 3 {
+		t.Fatalf("status %d, body %q after %d requests, want 200 ok after 3", resp.StatusCode, body, requests.Load())
+	}
+}
+
+func TestTransportRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	clock := &sleepClock{}
+	client := &http.Client{Transport: New(retry.RetryConfig{Clock: clock, MaxDelay: 10 * time.Second}, nil)}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	// Задержка берётся из Retry-After, а не из backoff
+	if resp.StatusCode != http.StatusOK || !slices.Equal(clock.sleeps, []time.Duration{7 * time.Second}) {
+		t.Fatalf("status %d after sleeps %v, want 200 after a single 7s sleep", resp.StatusCode, clock.sleeps)
+	}
+}
+
+func TestTransportGetBodyRewind(t *testing.T) {
+	srv, bodies := recordingServer(t, 2)
+	req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	getBodyCalls := 0
+	req.GetBody = func() (io.ReadCloser, error) {
+		getBodyCalls++
+		return io.NopCloser(strings.NewReader("payload")), nil
+	}
+
+	client := &http.Client{Transport: New(retry.RetryConfig{MaxAttempts: 3, Clock: &sleepClock{}}, nil)}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+
+	// Первая попытка отправляет исходное тело, каждый повтор — свежее из GetBody
+	if got := bodies(); !slices.Equal(got, []string{"payload", "payload", "payload"}) || getBodyCalls != 2 {
+		t.Fatalf("request bodies = %q with %d GetBody calls, want the full payload 3 times and 2 calls", got, getBodyCalls)
+	}
+}
+
+func TestTransportBodyReplay(t *testing.T) {
+	client := &http.Client{Transport: New(retry.RetryConfig{MaxAttempts: 3, Clock: &sleepClock{}}, nil)}
+
+	t.Run("bytes.Reader", func(t *testing.T) {
+		// http.NewRequest задаёт GetBody для bytes.Reader: тело перечитывается на каждом повторе
+		srv, bodies := recordingServer(t, 2)
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, bytes.NewReader([]byte("payload")))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		resp.Body.Close()
+		if got := bodies(); resp.StatusCode != http.StatusOK || !slices.Equal(got, []string{"payload", "payload", "payload"}) {
+			t.Fatalf("status %d, request bodies %q, want 200 with the full payload 3 times", resp.StatusCode, got)
+		}
+	})
+
+	t.Run("io.Pipe", func(t *testing.T) {
+		// Тело из потока перечитать нельзя: запрос выполняется один раз, возвращается первый ответ
+		srv, bodies := recordingServer(t, 2)
+		pr, pw := io.Pipe()
+		go func() {
+			_, _ = io.WriteString(pw, "payload")
+			pw.Close()
+		}()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, pr)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		resp.Body.Close()
+		if got := bodies(); resp.StatusCode != http.StatusServiceUnavailable || !slices.Equal(got, []string{"payload"}) {
+			t.Fatalf("status %d, request bodies %q, want the first 503 after a single request", resp.StatusCode, got)
+		}
+	})
+}
+
+func TestTransportRetryOnHeader(t *testing.T) {
+	type reply struct {
+		status      int
+		shouldRetry string
+	}
+	// classifyStatus передаёт классификатору конфигурации любой ответ с ошибочным статусом
+	classifyStatus := func(resp *http.Response) error {
+		if resp.StatusCode >= 400 {
+			return retry.NewHTTPError(resp)
+		}
+		return nil
+	}
+	tests := []struct {
+		name     string
+		replies  []reply
+		classify func(*http.Response) error
+		want     int
+		requests int32
+	}{
+		{"5xx retried on header", []reply{{503, "true"}, {200, ""}}, nil, 200, 2},
+		{"5xx not retried without header", []reply{{503, "false"}, {200, ""}}, nil, 503, 1},
+		{"4xx passed through by default", []reply{{409, "true"}, {200, ""}}, nil, 409, 1},
+		{"4xx retried via ClassifyResponse", []reply{{409, "true"}, {200, ""}}, classifyStatus, 200, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reply := tt.replies[min(int(requests.Add(1)), len(tt.replies))-1]
+				if reply.shouldRetry != "" {
+					w.Header().Set("X-Should-Retry", reply.shouldRetry)
+				}
+				w.WriteHeader(reply.status)
+			}))
+			t.Cleanup(srv.Close)
+
+			transport := New(retry.RetryConfig{
+				MaxAttempts: 3,
+				Clock:       &sleepClock{},
+				ShouldRetry: retry.RetryOnHeader("X-Should-Retry", "true"),
+			}, nil)
+			transport.ClassifyResponse = tt.classify
+			resp, err := (&http.Client{Transport: transport}).Get(srv.URL)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want || requests.Load() != tt.requests {
+				t.Fatalf("status %d after %d requests, want %d after %d", resp.StatusCode, requests.Load(), tt.want, tt.requests)
+			}
+		})
+	}
+}
+
+func TestTransportOperationTimeoutBodyReadable(t *testing.T) {
+	srv, _ := flakyServer(t, 1)
+	client := &http.Client{Transport: New(retry.RetryConfig{
+		OperationTimeout: 5 * time.Second,
+		MinDelay:         time.Millisecond,
+	}, nil)}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v, want nil", err)
+	}
+	if string(body) != "ok" {
+		t.Fatalf("body = %q, want %q", body, "ok")
+	}
+	if resp.Request.Context().Err() != nil {
+		t.Fatalf("response context canceled before body close")
+	}
+
+	resp.Body.Close()
+	if resp.Request.Context().Err() == nil {
+		t.Fatalf("response context not canceled after body close")
+	}
+}
+
+func TestTransportOperationTimeoutExceeded(t *testing.T) {
+	// Сервер отвечает дольше OperationTimeout: таймаут истекает во время попытки
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(300 * time.Millisecond):
+		}
+	}))
+	t.Cleanup(srv.Close)
+	client := &http.Client{Transport: New(retry.RetryConfig{
+		OperationTimeout: 50 * time.Millisecond,
+		MinDelay:         time.Millisecond,
+	}, nil)}
+
+	start := time.Now()
+	_, err := client.Get(srv.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get() error = %v, want context.DeadlineExceeded", err)
+	}
+	var retryErr *retry.RetryError
+	if !errors.As(err, &retryErr) || retryErr.Reason != retry.TimeBudgetExceeded {
+		t.Fatalf("Get() error = %v, want RetryError with %s", err, retry.TimeBudgetExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Get() took %s, want about OperationTimeout", elapsed)
+	}
+}
+
+// operationNames — retry.Metrics, запоминающий имена операций
+type operationNames struct {
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+func (m *operationNames) Attempt(operation string, _ time.Duration, _ error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.names == nil {
+		m.names = make(map[string]bool)
+	}
+	m.names[operation] = true
+}
+
+func (m *operationNames) Retry(string, int)                {}
+func (m *operationNames) Success(string, int)              {}
+func (m *operationNames) GiveUp(string, int, retry.Reason) {}
+
+func TestTransportBoundedOperationName(t *testing.T) {
+	srv, _ := flakyServer(t, 0)
+	metrics := &operationNames{}
+	client := &http.Client{Transport: New(retry.RetryConfig{Metrics: metrics}, nil)}
+
+	// Путь и query не попадают в имя операции, иначе метки Metrics росли бы без ограничений
+	for _, path := range []string{"/users/1?token=a", "/users/2?token=b", "/orders/3"} {
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", path, err)
+		}
+		resp.Body.Close()
+	}
+
+	host, _ := url.Parse(srv.URL)
+	want := "GET " + host.Host
+	if len(metrics.names) != 1 || !metrics.names[want] {
+		t.Fatalf("operation names = %v, want only %q", metrics.names, want)
+	}
+}
+
+// bodyErrorServer отвечает 200, но первые failures ответов содержат в теле код ошибки
+func bodyErrorServer(t *testing.T, failures int32, code string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			_, _ = io.WriteString(w, `{"error":"`+code+`"}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"result":"ok"}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+// classifyBody превращает код ошибки в теле ответа 200 в ошибку попытки и восстанавливает тело
+func classifyBody(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	switch {
+	case bytes.Contains(body, []byte(`"busy"`)):
+		return retry.WrapHTTPError(http.StatusServiceUnavailable, errors.New("backend busy"))
+	case bytes.Contains(body, []byte(`"invalid"`)):
+		return retry.WrapHTTPError(http.StatusBadRequest, errors.New("invalid request"))
+	}
+	return nil
+}
+
+func TestTransportClassifyResponse(t *testing.T) {
+	newClient := func() *http.Client {
+		transport := New(retry.RetryConfig{MaxAttempts: 3, MinDelay: time.Millisecond}, nil)
+		transport.ClassifyResponse = classifyBody
+		return &http.Client{Transport: transport}
+	}
+
+	t.Run("retriable body error", func(t *testing.T) {
+		srv, requests := bodyErrorServer(t, 2, "busy")
+		resp, err := newClient().Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != `{"result":"ok"}` || requests.Load() != 3 {
+			t.Fatalf("body = %s after %d requests, want the ok body after 3", body, requests.Load())
+		}
+	})
+
+	// Как и при ошибке по статусу, вызывающему отдаётся сам ответ
+	t.Run("non-retriable body error", func(t *testing.T) {
+		srv, requests := bodyErrorServer(t, 2, "invalid")
+		resp, err := newClient().Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Get() error = %v, want the response", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != `{"error":"invalid"}` || requests.Load() != 1 {
+			t.Fatalf("body = %s after %d requests, want the invalid body after 1", body, requests.Load())
+		}
+	})
+
+	t.Run("exhausted returns restored body", func(t *testing.T) {
+		srv, requests := bodyErrorServer(t, 5, "busy")
+		resp, err := newClient().Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Get() error = %v, want the last response", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != `{"error":"busy"}` || requests.Load() != 3 {
+			t.Fatalf("body = %s after %d requests, want the last busy body after 3", body, requests.Load())
+		}
+	})
+}
+
+func TestTransportRetryCount(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int32
+		wantStatus  int
+		wantRetries int
+	}{
+		{"first attempt", 0, http.StatusOK, 0},
+		{"one retry", 1, http.StatusOK, 1},
+		{"three retries", 3, http.StatusOK, 3},
+		{"attempts exhausted", 10, http.StatusServiceUnavailable, 4}, // Последний ответ из MaxAttempts
+	}
+	for _, tt := range tests {
+		for _, header := range []string{"", "X-Retry-Count"} {
+			t.Run(fmt.Sprintf("%s, header %q", tt.name, header), func(t *testing.T) {
+				srv, requests := flakyServer(t, tt.failures)
+				transport := New(retry.RetryConfig{MaxAttempts: 5, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}, nil)
+				transport.RetryCountHeader = header
+
+				resp, err := (&http.Client{Transport: transport}).Get(srv.URL)
+				if err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				resp.Body.Close()
+
+				if resp.StatusCode != tt.wantStatus || int(requests.Load()) != tt.wantRetries+1 {
+					t.Fatalf("status %d after %d requests, want %d after %d",
+						resp.StatusCode, requests.Load(), tt.wantStatus, tt.wantRetries+1)
+				}
+				if got := Retries(resp); got != tt.wantRetries {
+					t.Fatalf("Retries() = %d, want %d", got, tt.wantRetries)
+				}
+				got := resp.Header.Get("X-Retry-Count")
+				if header == "" && got != "" {
+					t.Fatalf("X-Retry-Count = %q without RetryCountHeader, want none", got)
+				}
+				if want := strconv.Itoa(tt.wantRetries); header != "" && got != want {
+					t.Fatalf("X-Retry-Count = %q, want %s", got, want)
+				}
+			})
+		}
+	}
+
+	// Ответ другого транспорта повторов не содержит
+	if got := Retries(&http.Response{Request: httptest.NewRequest(http.MethodGet, "/", nil)}); got != 0 {
+		t.Fatalf("Retries() of a foreign response = %d, want 0", got)
+	}
+	if Retries(nil) != 0 {
+		t.Fatal("Retries(nil) != 0")
+	}
+}
+
+func TestTransportExtraRetriableStatus(t *testing.T) {
+	// Сервер отвечает 409 на первый запрос, затем 200
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name         string
+		extra        []int
+		wantStatus   int
+		wantRequests int32
+	}{
+		{"not listed", nil, http.StatusConflict, 1},
+		{"listed", []int{http.StatusConflict}, http.StatusOK, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			client := &http.Client{Transport: New(retry.RetryConfig{
+				MaxAttempts:          3,
+				Clock:                &sleepClock{},
+				ExtraRetriableStatus: tt.extra,
+			}, nil)}
+
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus || requests.Load() != tt.wantRequests {
+				t.Fatalf("status %d after %d requests, want %d after %d",
+					resp.StatusCode, requests.Load(), tt.wantStatus, tt.wantRequests)
+			}
+		})
+	}
+}
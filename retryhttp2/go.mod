@@ -0,0 +1,7 @@
+module github.com/alfzs/retry/retryhttp2
+
+go 1.24.3
+
+require golang.org/x/net v0.38.0
+
+require golang.org/x/text v0.23.0 // indirect
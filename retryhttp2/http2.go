@@ -0,0 +1,36 @@
+// Package retryhttp2 классифицирует ошибки HTTP/2 из golang.org/x/net/http2.
+// Зависимость от x/net изолирована в этом модуле.
+package retryhttp2
+
+import (
+	"errors"
+
+	"golang.org/x/net/http2"
+)
+
+// IsRetriable сообщает, что запрос не был обработан сервером и его безопасно повторить:
+// соединение закрыто кадром GOAWAY или поток отклонён с REFUSED_STREAM
+func IsRetriable(err error) bool {
+	var goAway http2.GoAwayError
+	if errors.As(err, &goAway) {
+		return true
+	}
+	var streamErr http2.StreamError
+	if errors.As(err, &streamErr) {
+		return streamErr.Code == http2.ErrCodeRefusedStream
+	}
+	return false
+}
+
+// ShouldRetry дополняет классификатор next ошибками HTTP/2 из IsRetriable.
+// Для остальных ошибок решает next; nil означает «не повторять».
+//
+//	config.ShouldRetry = retryhttp2.ShouldRetry(myClassifier)
+func ShouldRetry(next func(error) bool) func(error) bool {
+	return func(err error) bool {
+		if IsRetriable(err) {
+			return true
+		}
+		return next != nil && next(err)
+	}
+}
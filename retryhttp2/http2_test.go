@@ -0,0 +1,51 @@
+package retryhttp2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"golang.org/x/net/http2"
+)
+
+func TestIsRetriable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"GOAWAY", http2.GoAwayError{LastStreamID: 5, ErrCode: http2.ErrCodeNo}, true},
+		{"wrapped GOAWAY", fmt.Errorf("round trip: %w", http2.GoAwayError{ErrCode: http2.ErrCodeEnhanceYourCalm}), true},
+		{"REFUSED_STREAM", http2.StreamError{StreamID: 3, Code: http2.ErrCodeRefusedStream}, true},
+		{"wrapped REFUSED_STREAM", fmt.Errorf("round trip: %w", http2.StreamError{StreamID: 3, Code: http2.ErrCodeRefusedStream}), true},
+		{"stream error with another code", http2.StreamError{StreamID: 3, Code: http2.ErrCodeProtocol}, false},
+		{"canceled stream", http2.StreamError{StreamID: 3, Code: http2.ErrCodeCancel}, false},
+		{"connection error", http2.ConnectionError(http2.ErrCodeProtocol), false},
+		{"other error", io.ErrUnexpectedEOF, false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetriable(tt.err); got != tt.want {
+				t.Fatalf("IsRetriable(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	errNext := errors.New("next decides")
+	next := func(err error) bool { return errors.Is(err, errNext) }
+
+	refused := http2.StreamError{Code: http2.ErrCodeRefusedStream}
+	if !ShouldRetry(next)(refused) || !ShouldRetry(nil)(refused) {
+		t.Fatal("ShouldRetry() = false for REFUSED_STREAM, want true")
+	}
+	if !ShouldRetry(next)(errNext) {
+		t.Fatal("ShouldRetry() = false for an error next retries, want true")
+	}
+	if ShouldRetry(next)(io.EOF) || ShouldRetry(nil)(errNext) {
+		t.Fatal("ShouldRetry() = true for an error neither HTTP/2 nor next retries, want false")
+	}
+}
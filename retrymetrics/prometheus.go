@@ -0,0 +1,83 @@
+// Package retrymetrics реализует retry.Metrics на основе Prometheus.
+// Зависимость от клиента Prometheus изолирована в этом модуле.
+package retrymetrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/alfzs/retry"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prometheus собирает метрики повторных попыток с меткой operation. Каждое имя операции
+// создаёт отдельные временные ряды, поэтому имена должны быть из ограниченного набора.
+type Prometheus struct {
+	attempts *prometheus.CounterVec
+	retries  *prometheus.CounterVec
+	success  *prometheus.CounterVec
+	giveUps  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+var _ retry.Metrics = (*Prometheus)(nil)
+
+// NewPrometheus создаёт метрики со стандартными именами без регистрации
+func NewPrometheus() *Prometheus {
+	return &Prometheus{
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "retry_attempts_total",
+			Help: "Total number of operation attempts.",
+		}, []string{"operation"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "retry_retries_total",
+			Help: "Total number of scheduled retries.",
+		}, []string{"operation"}),
+		success: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "retry_success_total",
+			Help: "Total number of successful operations by attempt number.",
+		}, []string{"operation", "attempts"}),
+		giveUps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "retry_giveup_total",
+			Help: "Total number of operations that gave up retrying.",
+		}, []string{"operation", "reason"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "retry_attempt_duration_seconds",
+			Help:    "Duration of individual operation attempts.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation"}),
+	}
+}
+
+// Register создаёт метрики и регистрирует их в reg
+func Register(reg prometheus.Registerer) (*Prometheus, error) {
+	p := NewPrometheus()
+	for _, c := range p.Collectors() {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Collectors возвращает все коллекторы для ручной регистрации
+func (p *Prometheus) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{p.attempts, p.retries, p.success, p.giveUps, p.duration}
+}
+
+func (p *Prometheus) Attempt(operation string, duration time.Duration, _ error) {
+	p.attempts.WithLabelValues(operation).Inc()
+	p.duration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+func (p *Prometheus) Retry(operation string, _ int) {
+	p.retries.WithLabelValues(operation).Inc()
+}
+
+func (p *Prometheus) Success(operation string, attempts int) {
+	p.success.WithLabelValues(operation, strconv.Itoa(attempts)).Inc()
+}
+
+func (p *Prometheus) GiveUp(operation string, _ int, reason retry.Reason) {
+	p.giveUps.WithLabelValues(operation, string(reason)).Inc()
+}
@@ -0,0 +1,59 @@
+package retrymetrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alfzs/retry"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPrometheusLabels(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	p, err := Register(reg)
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	p.Attempt("fetch", 10*time.Millisecond, errors.New("boom"))
+	p.Retry("fetch", 1)
+	p.Attempt("fetch", 10*time.Millisecond, nil)
+	p.Success("fetch", 2)
+	p.GiveUp("store", 3, retry.AttemptsExhausted)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	got := make(map[string]map[string]string) // Метрика -> метка -> значение
+	counts := make(map[string]int)            // Метрика -> число рядов
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			counts[family.GetName()]++
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			got[family.GetName()] = labels
+		}
+	}
+
+	want := map[string]map[string]string{
+		"retry_attempts_total":           {"operation": "fetch"},
+		"retry_retries_total":            {"operation": "fetch"},
+		"retry_success_total":            {"operation": "fetch", "attempts": "2"},
+		"retry_giveup_total":             {"operation": "store", "reason": string(retry.AttemptsExhausted)},
+		"retry_attempt_duration_seconds": {"operation": "fetch"},
+	}
+	for name, labels := range want {
+		if counts[name] != 1 {
+			t.Fatalf("%s: %d series, want 1", name, counts[name])
+		}
+		for key, value := range labels {
+			if got[name][key] != value {
+				t.Fatalf("%s{%s} = %q, want %q", name, key, got[name][key], value)
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package retry
+
+import "time"
+
+// Simulate возвращает задержки, которые WithRetry выдержал бы при failures неудачах подряд,
+// не вызывая операцию. Расписание считается тем же кодом, что и в WithRetry: стратегия,
+// FixedDelays, DelayHook, DelayRounding, MaxAttempts и бюджет времени (MaxElapsedTime,
+// Deadline) на симулированных часах, где попытки выполняются мгновенно.
+// Если Jitter не задан, используется DeterministicJitter(seed), поэтому результат
+// воспроизводим и совпадает с реальным прогоном с тем же Jitter.
+func Simulate(config RetryConfig, failures int, seed int64) []time.Duration {
+	if config.Jitter == nil {
+		config.Jitter = DeterministicJitter(uint64(seed))
+	}
+	config = withDefaults(config)
+	clock := &simulatedClock{now: config.Clock.Now()}
+	config.Clock = clock
+	start := clock.now
+
+	var delays []time.Duration
+	last := config.StartAttempt + failures
+	for attempt := config.StartAttempt; attempt < last && attempt < config.MaxAttempts; attempt++ {
+		delay := nextDelay(config, attempt, clock.now.Sub(start), nil, 1)
+		if remaining, ok := remainingBudget(config, start, config.Deadline); ok {
+			reserve := max(config.MinRemainingBudget, 0)
+			if remaining <= 0 || remaining < reserve {
+				break
+			}
+			delay = min(delay, remaining-reserve)
+		}
+		delays = append(delays, delay)
+		clock.now = clock.now.Add(delay)
+	}
+	return delays
+}
+
+// simulatedClock — часы Simulate, время которых сдвигается только расписанием
+type simulatedClock struct {
+	now time.Time
+}
+
+func (c *simulatedClock) Now() time.Time { return c.now }
+
+func (c *simulatedClock) After(d time.Duration) <-chan time.Time {
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
@@ -0,0 +1,52 @@
+package retry
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSimulateMatchesRun(t *testing.T) {
+	configs := map[string]RetryConfig{
+		"exponential": {MaxAttempts: 10, MinDelay: 100 * time.Millisecond, MaxDelay: 5 * time.Second},
+		"linear":      {MaxAttempts: 10, MinDelay: 100 * time.Millisecond, Backoff: LinearBackoff},
+		"time based":  {MaxAttempts: 10, Backoff: TimeBasedBackoff{DoublingTime: 2 * time.Second}},
+		"fixed":       {FixedDelays: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}},
+		"rounding":    {MaxAttempts: 10, MinDelay: 100 * time.Millisecond, DelayRounding: 250 * time.Millisecond},
+		"budget":      {MaxAttempts: 20, MinDelay: time.Second, MaxDelay: 8 * time.Second, MaxElapsedTime: 30 * time.Second},
+	}
+
+	for name, config := range configs {
+		t.Run(name, func(t *testing.T) {
+			for seed := range int64(20) {
+				for _, failures := range []int{1, 4, 25} {
+					want := Simulate(config, failures, seed)
+
+					// Реальный прогон с тем же сидом на фейковых часах: failures неудач, затем успех
+					run := config
+					run.Jitter = DeterministicJitter(uint64(seed))
+					clock := newFakeClock()
+					run.Clock = clock
+					_, _ = WithRetry(context.Background(), run, "op", failTimes(failures, errRetriable))
+
+					if got := clock.Sleeps(); !slices.Equal(got, want) {
+						t.Fatalf("seed %d, %d failures: run slept %v, Simulate() = %v", seed, failures, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSimulateLeavesConfigClock(t *testing.T) {
+	// Simulate не трогает часы конфигурации: все ожидания проходят на симулированных часах
+	clock := newManualClock()
+	delays := Simulate(RetryConfig{Clock: clock, MaxAttempts: 4}, 10, 1)
+	if len(delays) != 3 {
+		t.Fatalf("Simulate() = %v, want 3 delays limited by MaxAttempts", delays)
+	}
+	if len(clock.waiting) != 0 || !clock.Now().Equal(testEpoch) {
+		t.Fatalf("Simulate() used the configured clock, want a simulated one")
+	}
+}
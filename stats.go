@@ -0,0 +1,27 @@
+package retry
+
+import "sync/atomic"
+
+// Счётчики процесса для Stats
+var (
+	totalAttempts atomic.Int64
+	totalRetries  atomic.Int64
+	totalGiveUps  atomic.Int64
+)
+
+// ProcessStats — снимок счётчиков WithRetry по всему процессу
+type ProcessStats struct {
+	TotalAttempts int64 // Выполненные попытки
+	TotalRetries  int64 // Запланированные повторы
+	TotalGiveUps  int64 // Вызовы, завершившиеся RetryError
+}
+
+// Stats возвращает снимок счётчиков всех вызовов WithRetry в процессе — грубый сигнал
+// без настройки метрик. Для разбивки по операциям используйте Metrics.
+func Stats() ProcessStats {
+	return ProcessStats{
+		TotalAttempts: totalAttempts.Load(),
+		TotalRetries:  totalRetries.Load(),
+		TotalGiveUps:  totalGiveUps.Load(),
+	}
+}
@@ -0,0 +1,68 @@
+package retry
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestStatsConcurrent(t *testing.T) {
+	// Запускать с -race: счётчики растут из многих горутин, снимки читаются параллельно
+	const workers, calls = 16, 20
+	before := Stats()
+
+	var readers sync.WaitGroup
+	stop := make(chan struct{})
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		last := before
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			now := Stats()
+			if now.TotalAttempts < last.TotalAttempts || now.TotalRetries < last.TotalRetries || now.TotalGiveUps < last.TotalGiveUps {
+				t.Errorf("Stats() went backwards: %+v after %+v", now, last)
+				return
+			}
+			last = now
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range calls {
+				config := RetryConfig{Clock: newFakeClock(), MaxAttempts: 3}
+				if (w+i)%2 == 0 {
+					// Успех со второй попытки: 2 попытки, 1 повтор
+					_, _ = WithRetry(context.Background(), config, "op", failTimes(1, errRetriable))
+				} else {
+					// Отказ после трёх попыток: 3 попытки, 2 повтора, 1 отказ
+					op, _ := failing(errRetriable)
+					_, _ = WithRetry(context.Background(), config, "op", op)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	readers.Wait()
+
+	const half = workers * calls / 2
+	after := Stats()
+	got := ProcessStats{
+		TotalAttempts: after.TotalAttempts - before.TotalAttempts,
+		TotalRetries:  after.TotalRetries - before.TotalRetries,
+		TotalGiveUps:  after.TotalGiveUps - before.TotalGiveUps,
+	}
+	want := ProcessStats{TotalAttempts: half*2 + half*3, TotalRetries: half + half*2, TotalGiveUps: half}
+	if got != want {
+		t.Fatalf("Stats() delta = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+package retry
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// StepResult — итог одной попытки Step
+type StepResult[T any] struct {
+	Value T
+	Err   error // Ошибка попытки или *RetryError при окончательной неудаче
+
+	// Done сообщает, что вызывать Step больше не нужно: операция успешна
+	// или попытки прекращены. Иначе следующую попытку NextAttempt нужно
+	// выполнить через Delay.
+	Done        bool
+	NextAttempt int
+	Delay       time.Duration
+}
+
+// Step выполняет одну попытку attempt (с 1) и вместо ожидания возвращает решение о повторе.
+// Это позволяет отдать ожидание внешнему планировщику (например, движку workflow):
+// сохранить NextAttempt, а через Delay вызвать Step снова, в том числе в другом процессе.
+// Учитываются классификация ошибок, MaxAttempts, MaxAttemptsFor, Budget и SuccessErrors;
+// Metrics, Events, OnRetry и счётчики Stats получают те же события, что и в WithRetry.
+//
+// Step не хранит состояния между вызовами, поэтому часть параметров действует иначе:
+//   - MaxAttemptsFor сравнивает с лимитом класса общий номер attempt, а не число неудач класса;
+//   - CountAttempt не применяется: каждый вызов засчитывается как попытка;
+//   - PersistState не вызывается: сохранять нужно сам StepResult;
+//   - время между вызовами не отслеживается, поэтому MaxElapsedTime и Deadline не действуют.
+func Step[T any](
+	ctx context.Context,
+	config RetryConfig,
+	operationName string,
+	attempt int,
+	operationFn func(context.Context) (T, error),
+) StepResult[T] {
+	if operationFn == nil {
+		return StepResult[T]{Err: ErrNilOperation, Done: true}
+	}
+
+	config = withDefaults(config)
+	if n, ok := MaxAttemptsFrom(ctx); ok {
+		config.MaxAttempts = n
+	}
+	attempt = max(attempt, 1)
+
+	emitEvent(config, Event{Type: EventAttemptStart, Operation: operationName, Attempt: attempt})
+	attemptStart := config.Clock.Now()
+	value, err := operationFn(ctx)
+	totalAttempts.Add(1)
+	if config.Metrics != nil {
+		config.Metrics.Attempt(operationName, config.Clock.Now().Sub(attemptStart), err)
+	}
+	if err != nil && isSuccessError(config, err) {
+		err = nil
+	}
+	if err == nil {
+		emitEvent(config, Event{Type: EventSuccess, Operation: operationName, Attempt: attempt})
+		if config.Metrics != nil {
+			config.Metrics.Success(operationName, attempt)
+		}
+		return StepResult[T]{Value: value, Done: true}
+	}
+	emitEvent(config, Event{Type: EventFailure, Operation: operationName, Attempt: attempt, Err: err})
+	if stopErr, ok := stopped(err); ok {
+		return StepResult[T]{Value: value, Err: stopErr, Done: true}
+	}
+	if ctx.Err() != nil {
+		return StepResult[T]{Value: value, Err: canceledError(ctx, err), Done: true}
+	}
+
+	var reason Reason
+	retriable, multiplier := classify(ctx, config, err)
+	maxAttempts := config.MaxAttempts
+	if config.MaxAttemptsFor != nil {
+		if limit := config.MaxAttemptsFor(err); limit > 0 {
+			maxAttempts = limit
+		}
+	}
+	switch {
+	case !retriable:
+		reason = NonRetriable
+	case attempt >= maxAttempts || Disabled(ctx) || !RetriesEnabled():
+		reason = AttemptsExhausted
+	case config.Budget != nil && !config.Budget.allow(config.Clock.Now()):
+		reason = BudgetExhausted
+	}
+	if reason != "" {
+		totalGiveUps.Add(1)
+		if config.Metrics != nil {
+			config.Metrics.GiveUp(operationName, attempt, reason)
+		}
+		emitEvent(config, Event{Type: EventGiveUp, Operation: operationName, Attempt: attempt, Err: err, Reason: reason})
+		if config.ZeroResultOnError {
+			var zero T
+			value = zero
+		}
+		retryErr := &RetryError{Operation: operationName, Attempts: attempt, Reason: reason, LastError: err}
+		if attempt == 1 {
+			retryErr.FirstError = err
+		}
+		return StepResult[T]{Value: value, Err: retryErr, Done: true}
+	}
+
+	delay := nextDelay(config, attempt, 0, err, multiplier)
+	logAttrs(ctx, config, slog.LevelError, "Operation failed, retry scheduled",
+		slog.String("operation", operationName),
+		slog.Int("attempt", attempt),
+		slog.Int("next_attempt", attempt+1),
+		slog.Int("max_attempt", maxAttempts),
+		slog.Duration("delay", delay),
+		slog.Any("error", err))
+	totalRetries.Add(1)
+	if config.Metrics != nil {
+		config.Metrics.Retry(operationName, attempt)
+	}
+	if config.OnRetry != nil {
+		config.OnRetry(attempt, maxAttempts, delay, err)
+	}
+	emitEvent(config, Event{Type: EventSleep, Operation: operationName, Attempt: attempt, Delay: delay})
+
+	return StepResult[T]{Value: value, Err: err, NextAttempt: attempt + 1, Delay: delay}
+}
@@ -0,0 +1,80 @@
+package retry
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStepScheduleAndResume(t *testing.T) {
+	// Внешний планировщик хранит только NextAttempt и ждёт Delay; вызовы идут как в разных процессах
+	events := make(chan Event, 16)
+	var retried []int
+	config := RetryConfig{
+		MaxAttempts: 3,
+		MinDelay:    time.Second,
+		MaxDelay:    time.Minute,
+		Jitter:      noJitter,
+		Events:      events,
+		OnRetry:     func(attempt, _ int, _ time.Duration, _ error) { retried = append(retried, attempt) },
+	}
+	before := Stats()
+
+	var delays []time.Duration
+	attempt := 1
+	var res StepResult[int]
+	for {
+		op, _ := failing(errRetriable)
+		res = Step(context.Background(), config, "op", attempt, op)
+		if res.Done {
+			break
+		}
+		if res.NextAttempt != attempt+1 {
+			t.Fatalf("NextAttempt = %d after attempt %d, want %d", res.NextAttempt, attempt, attempt+1)
+		}
+		delays = append(delays, res.Delay)
+		attempt = res.NextAttempt
+	}
+
+	retryErr := requireReason(t, res.Err, AttemptsExhausted)
+	if retryErr.Attempts != 3 {
+		t.Fatalf("Attempts = %d, want 3", retryErr.Attempts)
+	}
+	// Задержки совпадают с расписанием WithRetry для той же конфигурации
+	if want := []time.Duration{config.NextDelay(2), config.NextDelay(3)}; !slices.Equal(delays, want) {
+		t.Fatalf("delays = %v, want %v", delays, want)
+	}
+	if !slices.Equal(retried, []int{1, 2}) {
+		t.Fatalf("OnRetry attempts = %v, want [1 2]", retried)
+	}
+	wantEvents := []EventType{
+		EventAttemptStart, EventFailure, EventSleep,
+		EventAttemptStart, EventFailure, EventSleep,
+		EventAttemptStart, EventFailure, EventGiveUp,
+	}
+	close(events)
+	var got []EventType
+	for e := range events {
+		got = append(got, e.Type)
+	}
+	if !slices.Equal(got, wantEvents) {
+		t.Fatalf("events = %v, want %v", got, wantEvents)
+	}
+	after := Stats()
+	if after.TotalAttempts-before.TotalAttempts != 3 || after.TotalRetries-before.TotalRetries != 2 ||
+		after.TotalGiveUps-before.TotalGiveUps != 1 {
+		t.Fatalf("Stats delta = %+v -> %+v, want 3 attempts, 2 retries, 1 give-up", before, after)
+	}
+}
+
+func TestStepClassLimitUsesOverallAttempt(t *testing.T) {
+	// Step не помнит неудач по классам: лимит класса сравнивается с общим номером попытки
+	config := RetryConfig{MaxAttempts: 10, MaxAttemptsFor: func(error) int { return 2 }}
+	op, _ := failing(errRetriable)
+	if res := Step(context.Background(), config, "op", 1, op); res.Done {
+		t.Fatalf("Step(1) Done, want retry below the class limit")
+	}
+	res := Step(context.Background(), config, "op", 2, op)
+	requireReason(t, res.Err, AttemptsExhausted)
+}
@@ -0,0 +1,44 @@
+package retry
+
+import "errors"
+
+// ErrStop прекращает повторы по решению самой операции: WithRetry сразу возвращает
+// результат попытки и ошибку без дальнейших попыток. Успехом это не считается.
+var ErrStop = errors.New("retry: stopped by operation")
+
+// stopError — ошибка StopRetry, несущая ошибку для вызывающего
+type stopError struct {
+	err error
+}
+
+func (e *stopError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stopError) Unwrap() error {
+	return e.err
+}
+
+func (e *stopError) Is(target error) bool {
+	return target == ErrStop
+}
+
+// StopRetry возвращается из операции, чтобы прекратить повторы: WithRetry вернёт result и err
+// как есть. При err == nil возвращается ErrStop.
+//
+//	return retry.StopRetry(partial, err)
+func StopRetry[T any](result T, err error) (T, error) {
+	if err == nil {
+		return result, ErrStop
+	}
+	return result, &stopError{err: err}
+}
+
+// stopped сообщает, запросила ли операция остановку, и возвращает ошибку для вызывающего
+func stopped(err error) (error, bool) {
+	var stop *stopError
+	if errors.As(err, &stop) {
+		return stop.err, true
+	}
+	return err, errors.Is(err, ErrStop)
+}
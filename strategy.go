@@ -0,0 +1,113 @@
+package retry
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// BackoffStrategy вычисляет задержку без jitter после неудачной попытки attempt (с 1).
+// Jitter и ограничение MaxDelay применяются поверх результата.
+type BackoffStrategy interface {
+	Delay(attempt int, minDelay, maxDelay time.Duration) time.Duration
+}
+
+// ElapsedBackoffStrategy — стратегия, задержка которой зависит от времени с начала повторов,
+// а не от номера попытки. WithRetry вызывает DelayAfter вместо Delay, отсчитывая время по Clock.
+type ElapsedBackoffStrategy interface {
+	BackoffStrategy
+	DelayAfter(elapsed, minDelay, maxDelay time.Duration) time.Duration
+}
+
+// BackoffFunc позволяет использовать функцию как BackoffStrategy
+type BackoffFunc func(attempt int, minDelay, maxDelay time.Duration) time.Duration
+
+func (f BackoffFunc) Delay(attempt int, minDelay, maxDelay time.Duration) time.Duration {
+	return f(attempt, minDelay, maxDelay)
+}
+
+// Встроенные стратегии backoff
+var (
+	// ExponentialBackoff: minDelay*2^(attempt-1), показатель ограничивается RetryConfig.MaxExponent
+	ExponentialBackoff BackoffStrategy = exponentialBackoff{}
+	// LinearBackoff: minDelay*attempt
+	LinearBackoff BackoffStrategy = BackoffFunc(linearDelay)
+	// ConstantBackoff: всегда minDelay
+	ConstantBackoff BackoffStrategy = BackoffFunc(func(_ int, minDelay, _ time.Duration) time.Duration {
+		return minDelay
+	})
+	// FibonacciBackoff: minDelay*F(attempt), где F = 1, 1, 2, 3, 5, ...
+	FibonacciBackoff BackoffStrategy = BackoffFunc(fibonacciDelay)
+)
+
+// TimeBasedBackoff растит задержку со временем: minDelay*2^(elapsed/DoublingTime),
+// то есть удваивает её каждые DoublingTime независимо от числа попыток.
+// Полезна, когда попытки быстрые и частые. DoublingTime <= 0 даёт постоянную minDelay.
+type TimeBasedBackoff struct {
+	DoublingTime time.Duration
+}
+
+// Delay возвращает minDelay: без времени с начала повторов рост не определён
+func (b TimeBasedBackoff) Delay(_ int, minDelay, maxDelay time.Duration) time.Duration {
+	return b.DelayAfter(0, minDelay, maxDelay)
+}
+
+// DelayAfter вычисляет задержку спустя elapsed от начала повторов, в пределах [minDelay, maxDelay]
+func (b TimeBasedBackoff) DelayAfter(elapsed, minDelay, maxDelay time.Duration) time.Duration {
+	if b.DoublingTime <= 0 || elapsed <= 0 {
+		return min(minDelay, maxDelay)
+	}
+	delay := float64(minDelay) * math.Exp2(float64(elapsed)/float64(b.DoublingTime))
+	if delay >= float64(maxDelay) {
+		return maxDelay
+	}
+	return max(time.Duration(delay), minDelay)
+}
+
+// exponentialBackoff — отдельный тип, чтобы WithRetry узнавал экспоненциальную стратегию для MaxExponent
+type exponentialBackoff struct{}
+
+func (exponentialBackoff) Delay(attempt int, minDelay, maxDelay time.Duration) time.Duration {
+	return baseExponentialDelay(attempt, minDelay, maxDelay)
+}
+
+// ParseBackoff возвращает встроенную стратегию по имени: "exponential", "linear",
+// "constant" или "fibonacci" (без учёта регистра)
+func ParseBackoff(name string) (BackoffStrategy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "exponential":
+		return ExponentialBackoff, nil
+	case "linear":
+		return LinearBackoff, nil
+	case "constant":
+		return ConstantBackoff, nil
+	case "fibonacci":
+		return FibonacciBackoff, nil
+	}
+	return nil, fmt.Errorf("retry: unknown backoff strategy %q", name)
+}
+
+// linearDelay вычисляет minDelay*attempt, не больше maxDelay
+func linearDelay(attempt int, minDelay, maxDelay time.Duration) time.Duration {
+	if minDelay <= 0 {
+		return 0
+	}
+	attempt = max(attempt, 1)
+	if time.Duration(attempt) > maxDelay/minDelay {
+		return maxDelay
+	}
+	return min(minDelay*time.Duration(attempt), maxDelay)
+}
+
+// fibonacciDelay вычисляет minDelay*F(attempt), не больше maxDelay
+func fibonacciDelay(attempt int, minDelay, maxDelay time.Duration) time.Duration {
+	prev, cur := time.Duration(0), minDelay
+	for i := 1; i < attempt && cur < maxDelay; i++ {
+		if cur > math.MaxInt64-prev {
+			return maxDelay
+		}
+		prev, cur = cur, prev+cur
+	}
+	return min(cur, maxDelay)
+}
@@ -0,0 +1,231 @@
+package retry
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseBackoff(t *testing.T) {
+	const minDelay, maxDelay = 100 * time.Millisecond, 10 * time.Second
+	tests := []struct {
+		name string
+		want []time.Duration // Задержки после неудач 1..5
+	}{
+		{"exponential", []time.Duration{100, 200, 400, 800, 1600}},
+		{"Linear", []time.Duration{100, 200, 300, 400, 500}},
+		{" constant ", []time.Duration{100, 100, 100, 100, 100}},
+		{"fibonacci", []time.Duration{100, 100, 200, 300, 500}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := ParseBackoff(tt.name)
+			if err != nil {
+				t.Fatalf("ParseBackoff(%q) error = %v", tt.name, err)
+			}
+			for i, want := range tt.want {
+				if got := strategy.Delay(i+1, minDelay, maxDelay); got != want*time.Millisecond {
+					t.Fatalf("Delay(%d) = %s, want %s", i+1, got, want*time.Millisecond)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBackoffUnknown(t *testing.T) {
+	if _, err := ParseBackoff("quadratic"); err == nil {
+		t.Fatalf("ParseBackoff(quadratic) error = nil, want error")
+	}
+
+	calls := 0
+	_, err := WithRetry(context.Background(), RetryConfig{BackoffName: "quadratic"}, "op", func(context.Context) (int, error) {
+		calls++
+		return 0, nil
+	})
+	if err == nil || calls != 0 {
+		t.Fatalf("WithRetry() error = %v, calls = %d, want error without running the operation", err, calls)
+	}
+}
+
+func TestStrategiesNonPositiveMinDelay(t *testing.T) {
+	strategies := map[string]BackoffStrategy{
+		"exponential": ExponentialBackoff,
+		"linear":      LinearBackoff,
+		"constant":    ConstantBackoff,
+		"fibonacci":   FibonacciBackoff,
+	}
+	for name, strategy := range strategies {
+		for _, minDelay := range []time.Duration{0, -time.Second} {
+			if got := strategy.Delay(2, minDelay, time.Second); got > 0 {
+				t.Fatalf("%s.Delay(2, %s, 1s) = %s, want no positive delay", name, minDelay, got)
+			}
+		}
+	}
+	if got := ExponentialDelay(3, 0, time.Second); got > 0 {
+		t.Fatalf("ExponentialDelay(3, 0, 1s) = %s, want no positive delay", got)
+	}
+}
+
+func TestTimeBasedBackoffDelayAfter(t *testing.T) {
+	backoff := TimeBasedBackoff{DoublingTime: 10 * time.Second}
+	const minDelay, maxDelay = time.Second, 30 * time.Second
+	tests := []struct {
+		elapsed time.Duration
+		want    time.Duration
+	}{
+		{0, time.Second},
+		{5 * time.Second, 1414213562}, // √2 s
+		{10 * time.Second, 2 * time.Second},
+		{30 * time.Second, 8 * time.Second},
+		{time.Minute, maxDelay},
+		{-time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		if got := backoff.DelayAfter(tt.elapsed, minDelay, maxDelay); got != tt.want {
+			t.Fatalf("DelayAfter(%s) = %s, want %s", tt.elapsed, got, tt.want)
+		}
+	}
+	if got := (TimeBasedBackoff{}).DelayAfter(time.Hour, minDelay, maxDelay); got != minDelay {
+		t.Fatalf("DelayAfter without DoublingTime = %s, want MinDelay", got)
+	}
+}
+
+func TestTimeBasedBackoffWithFakeClock(t *testing.T) {
+	// Задержка зависит от времени с начала повторов, а не от номера попытки:
+	// медленные попытки растят её быстрее, чем быстрые
+	run := func(attemptDuration time.Duration) []time.Duration {
+		clock := newFakeClock()
+		_, _ = WithRetry(context.Background(), RetryConfig{
+			Clock:       clock,
+			MaxAttempts: 4,
+			MinDelay:    time.Second,
+			MaxDelay:    time.Minute,
+			Backoff:     TimeBasedBackoff{DoublingTime: 10 * time.Second},
+			Jitter:      noJitter,
+		}, "op", func(context.Context) (int, error) {
+			clock.Advance(attemptDuration)
+			return 0, errRetriable
+		})
+		return clock.Sleeps()
+	}
+
+	backoff := TimeBasedBackoff{DoublingTime: 10 * time.Second}
+	for _, attemptDuration := range []time.Duration{0, 9 * time.Second} {
+		sleeps := run(attemptDuration)
+		elapsed := time.Duration(0)
+		for i, got := range sleeps {
+			elapsed += attemptDuration
+			if want := backoff.DelayAfter(elapsed, time.Second, time.Minute); got != want {
+				t.Fatalf("attempt %s: delay %d = %s after %s, want %s", attemptDuration, i+1, got, elapsed, want)
+			}
+			elapsed += got
+		}
+	}
+
+	fast, slow := run(0), run(9*time.Second)
+	if last := len(slow) - 1; slow[last] <= fast[last] {
+		t.Fatalf("delays with slow attempts %v, want above fast attempts %v", slow, fast)
+	}
+}
+
+func TestMaxExponentPlateau(t *testing.T) {
+	config := RetryConfig{
+		MaxAttempts: 10,
+		MinDelay:    100 * time.Millisecond,
+		MaxDelay:    time.Hour,
+		MaxExponent: 3,
+		Jitter:      noJitter,
+	}
+
+	// Реальный прогон: рост останавливается на MinDelay*2^3, задолго до MaxDelay
+	clock := newFakeClock()
+	run := config
+	run.Clock = clock
+	op, _ := failing(errRetriable)
+	_, err := WithRetry(context.Background(), run, "op", op)
+	requireReason(t, err, AttemptsExhausted)
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}
+	for len(want) < 9 {
+		want = append(want, 800*time.Millisecond)
+	}
+	if sleeps := clock.Sleeps(); !slices.Equal(sleeps, want) {
+		t.Fatalf("sleeps = %v, want %v", sleeps, want)
+	}
+
+	// Без MaxExponent та же конфигурация растёт дальше
+	config.MaxExponent = 0
+	if delays := Simulate(config, 9, 0); delays[8] <= 800*time.Millisecond {
+		t.Fatalf("Simulate() without MaxExponent = %v, want growth past the exponent cap", delays)
+	}
+
+	// На другие стратегии MaxExponent не влияет
+	config.MaxExponent = 3
+	config.Backoff = LinearBackoff
+	if delays := Simulate(config, 9, 0); delays[8] != 900*time.Millisecond {
+		t.Fatalf("Simulate() with LinearBackoff = %v, want linear growth to 900ms", delays)
+	}
+}
+
+func TestExponentialLargeAttempt(t *testing.T) {
+	const minDelay, maxDelay = 100 * time.Millisecond, 30 * time.Second
+	for _, attempt := range []int{64, 1000, math.MaxInt32} {
+		if got := ExponentialBackoff.Delay(attempt, minDelay, maxDelay); got != maxDelay {
+			t.Fatalf("ExponentialBackoff.Delay(%d) = %s, want %s", attempt, got, maxDelay)
+		}
+		// С jitter задержка не обязана совпадать с maxDelay, но остаётся в (0, maxDelay]
+		if got := ExponentialDelay(attempt, minDelay, maxDelay); got <= 0 || got > maxDelay {
+			t.Fatalf("ExponentialDelay(%d) = %s, want within (0, %s]", attempt, got, maxDelay)
+		}
+	}
+
+	config := RetryConfig{MinDelay: minDelay, MaxDelay: maxDelay}
+	if got := config.NextDelay(1000); got != maxDelay {
+		t.Fatalf("NextDelay(1000) = %s, want %s", got, maxDelay)
+	}
+}
+
+func TestExponentialDelayMatchesWithRetry(t *testing.T) {
+	// Jitter пакета backoff случаен, поэтому задержки сравниваются по допустимому диапазону:
+	// [base/2, min(base*3/2, maxDelay)], где base = minDelay*2^(attempt-1) не больше maxDelay
+	const minDelay, maxDelay, attempts = 100 * time.Millisecond, 2 * time.Second, 10
+	within := func(attempt int, d time.Duration) bool {
+		base := ExponentialBackoff.Delay(attempt, minDelay, maxDelay)
+		return d >= base/2 && d <= min(base*3/2, maxDelay)
+	}
+
+	for run := 0; run < 20; run++ {
+		clock := newFakeClock()
+		op, _ := failing(errRetriable)
+		_, err := WithRetry(context.Background(), RetryConfig{
+			Clock:       clock,
+			MaxAttempts: attempts,
+			MinDelay:    minDelay,
+			MaxDelay:    maxDelay,
+		}, "op", op)
+		requireReason(t, err, AttemptsExhausted)
+
+		sleeps := clock.Sleeps()
+		if len(sleeps) != attempts-1 {
+			t.Fatalf("sleeps = %v, want %d", sleeps, attempts-1)
+		}
+		for i, sleep := range sleeps {
+			attempt := i + 1
+			if !within(attempt, sleep) {
+				t.Fatalf("WithRetry sleep after attempt %d = %s, outside the ExponentialDelay range", attempt, sleep)
+			}
+			if d := ExponentialDelay(attempt, minDelay, maxDelay); !within(attempt, d) {
+				t.Fatalf("ExponentialDelay(%d) = %s, outside the range of WithRetry delays", attempt, d)
+			}
+		}
+	}
+
+	// Без minDelay задержки нет
+	for _, minDelay := range []time.Duration{0, -time.Second} {
+		if got := ExponentialDelay(3, minDelay, time.Second); got != 0 {
+			t.Fatalf("ExponentialDelay(3, %s, 1s) = %s, want 0", minDelay, got)
+		}
+	}
+}